@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/nuts-foundation/data-viewer/analyzers"
+	"github.com/nuts-foundation/go-did/did"
+	"github.com/nuts-foundation/nuts-node/network/dag"
+	"strings"
+	"time"
+
+	ui "github.com/gizak/termui/v3"
+	"github.com/gizak/termui/v3/widgets"
+)
+
+var showAbout bool = false
+
+// renderAbout renders an overlay summarizing everything known about the given transaction
+func renderAbout(rawTransaction string, width int, height int) {
+	p := widgets.NewParagraph()
+	p.Title = "| About this transaction |"
+	p.Text = describeTransaction(rawTransaction)
+	p.SetRect(0, 0, width-1, height-1)
+	ui.Render(p)
+}
+
+// describeTransaction gathers all facts about the given transaction (at the current position) into readable lines
+func describeTransaction(rawTransaction string) string {
+	var lines []string
+	add := func(name string, value string) {
+		lines = append(lines, fmt.Sprintf("%-14s %s", name, value))
+	}
+
+	add("Position:", fmt.Sprintf("%d.%d (transaction %d of %d at lamport clock %d)", dagLamportClock, dagSubIndex,
+		dagSubIndex+1, len(transactions[dagLamportClock]), dagLamportClock))
+	tx, err := dag.ParseTransaction([]byte(rawTransaction))
+	if err != nil {
+		add("Error:", fmt.Sprintf("failed to parse transaction: %v", err))
+		return strings.Join(lines, "\n")
+	}
+	add("Hash:", displayHash(tx.Ref()))
+	add("Content type:", tx.PayloadType())
+	add("Signed at:", tx.SigningTime().Format(time.RFC3339))
+	if tx.SigningKey() != nil {
+		add("Signing key:", fmt.Sprintf("embedded JWK (kid: %s)", tx.SigningKey().KeyID()))
+	} else {
+		add("Signing key:", tx.SigningKeyID())
+	}
+	add("Algorithm:", tx.SigningAlgorithm())
+	if signature, err := decodeSignature(rawTransaction); err != nil {
+		add("Signature:", err.Error())
+	} else if signature == nil {
+		add("Signature:", "none")
+	} else {
+		add("Signature:", fmt.Sprintf("%d bytes, produced with %s", len(signature), tx.SigningAlgorithm()))
+		for i, line := range chunk(base64.RawURLEncoding.EncodeToString(signature), 64) {
+			if i == 0 {
+				add("  base64:", line)
+			} else {
+				add("", line)
+			}
+		}
+		for i, line := range chunk(hex.EncodeToString(signature), 64) {
+			if i == 0 {
+				add("  hex:", line)
+			} else {
+				add("", line)
+			}
+		}
+	}
+	if tx.PAL() != nil {
+		add("Private:", fmt.Sprintf("yes, encrypted for %d participants", len(tx.PAL())))
+	}
+	if len(tx.Previous()) == 0 {
+		add("Previous:", "none (root transaction)")
+	}
+	for i, prev := range tx.Previous() {
+		if i == 0 {
+			add("Previous:", displayHash(prev))
+		} else {
+			add("", displayHash(prev))
+		}
+	}
+
+	payload, err := loadPayload(tx.Ref())
+	if err != nil {
+		add("Payload:", fmt.Sprintf("unavailable: %v", err))
+		return strings.Join(lines, "\n")
+	}
+	add("Payload:", fmt.Sprintf("%d bytes %s", len(payload), verifyPayloadHash(tx)))
+	add("Payload hash:", displayHash(tx.PayloadHash()))
+	kind := jsonKind(payload)
+	add("Payload kind:", describeJSONKind(kind))
+	// Only objects can be DID documents, don't bother parsing anything else
+	if tx.PayloadType() == didDocumentType && kind == "object" {
+		document := did.Document{}
+		if err := json.Unmarshal(payload, &document); err != nil {
+			add("DID:", fmt.Sprintf("invalid DID document: %v", err))
+		} else {
+			add("DID:", document.ID.String())
+			add("State:", strings.Join(analyzers.Classify(tx, &document), ", "))
+			for i, line := range describeControllers(document) {
+				if i == 0 {
+					add("Controllers:", line)
+				} else {
+					add("", line)
+				}
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// chunk splits the given text into lines of at most the given length, so long unbroken values (e.g. signatures) wrap
+func chunk(text string, length int) []string {
+	var lines []string
+	for len(text) > length {
+		lines = append(lines, text[:length])
+		text = text[length:]
+	}
+	return append(lines, text)
+}
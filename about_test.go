@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDescribeTransaction_PayloadKind(t *testing.T) {
+	testCases := []struct {
+		name     string
+		payload  string
+		kind     string
+		document bool
+	}{
+		{"DID document", `{"id":"did:nuts:A","verificationMethod":[{"id":"did:nuts:A#key-1"}]}`, "JSON object", true},
+		{"array", `[{"id":"did:nuts:A"}]`, "JSON array", false},
+		{"scalar", `42`, "JSON number", false},
+		{"not JSON", `hello`, "not JSON", false},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			rawTransaction := withCachedPayload(t, testCase.payload)
+
+			description := describeTransaction(rawTransaction)
+
+			if !strings.Contains(description, "Payload kind:  "+testCase.kind) {
+				t.Errorf("expected payload kind %s, got:\n%s", testCase.kind, description)
+			}
+			// Only a JSON object is described as DID document, anything else doesn't show an error either
+			if strings.Contains(description, "DID:  ") != testCase.document {
+				t.Errorf("expected DID document lines: %v, got:\n%s", testCase.document, description)
+			}
+			if strings.Contains(description, "invalid DID document") {
+				t.Errorf("expected no error, got:\n%s", description)
+			}
+		})
+	}
+}
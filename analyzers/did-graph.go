@@ -3,6 +3,7 @@ package analyzers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/nuts-foundation/go-did/did"
 	"github.com/nuts-foundation/nuts-node/crypto/hash"
@@ -10,108 +11,239 @@ import (
 	"github.com/nuts-foundation/nuts-node/network/dag"
 	vdrAPI "github.com/nuts-foundation/nuts-node/vdr/api/v1"
 	"io"
+	"net/http"
+	"sort"
 	"strings"
+	"time"
 )
 
+// VDRClient is the part of the VDR API client (vdrAPI.Client) used to resolve DID documents
+type VDRClient interface {
+	GetDID(ctx context.Context, did string, params *vdrAPI.GetDIDParams, reqEditors ...vdrAPI.RequestEditorFn) (*http.Response, error)
+}
+
+// NetworkClient is the part of the network API client (networkAPI.Client) used to read transactions
+type NetworkClient interface {
+	GetTransaction(ctx context.Context, ref string, reqEditors ...networkAPI.RequestEditorFn) (*http.Response, error)
+	GetTransactionPayload(ctx context.Context, ref string, reqEditors ...networkAPI.RequestEditorFn) (*http.Response, error)
+	ListTransactions(ctx context.Context, params *networkAPI.ListTransactionsParams, reqEditors ...networkAPI.RequestEditorFn) (*http.Response, error)
+}
+
+// RequestEditorFn edits every HTTP request the analyzer sends to the node, e.g. to add custom headers.
+// It has the same signature as the RequestEditorFn of the nuts-node API clients.
+type RequestEditorFn func(ctx context.Context, req *http.Request) error
+
+// ErrTooManyNodes is returned by Analyze when the graph grows beyond Options.MaxNodes
+var ErrTooManyNodes = errors.New("graph has too many nodes")
+
+// DIDDocumentGraphAnalyzer builds a graph of the transactions that make up the history of one or more DID documents
 type DIDDocumentGraphAnalyzer struct {
-	VDR     *vdrAPI.Client
-	Network *networkAPI.Client
+	vdr     VDRClient
+	network NetworkClient
+	editors []RequestEditorFn
+}
+
+// Options configures an analysis
+type Options struct {
+	// MinLC and MaxLC limit the graph to transactions within the lamport clock window [MinLC, MaxLC]. Transactions just
+	// outside the window that are referenced by (or refer to) transactions inside it are included as boundary nodes,
+	// with the note "older" or "newer".
+	// A MaxLC of 0 means there's no upper limit.
+	MinLC uint32
+	MaxLC uint32
+	// StopAtDeactivation treats deactivation as the end of a DID document's lifecycle: transactions that follow a
+	// deactivation are left out of the graph, and the deactivation itself is marked as terminal.
+	StopAtDeactivation bool
+	// TransactionTimeout limits the time reading a single transaction (and its payload) may take, so one slow
+	// transaction fails fast instead of consuming the time of the whole analysis. 0 means no limit.
+	TransactionTimeout time.Duration
+	// AsOf limits the graph to the given transaction (a version of a DID document) and its history, leaving out the
+	// versions that came after it. It must be part of the history of the analyzed DIDs and/or TXs. The lamport clock
+	// window (MinLC and MaxLC) is applied afterwards, so it can further limit the history of that version.
+	AsOf hash.SHA256Hash
+	// SkipControllers keeps the graph strictly within the analyzed DIDs, leaving out their controllers (and other
+	// documents they control). Transactions of controllers aren't traversed, so history of a DID document that only
+	// runs through them (instead of referring to its previous version directly) is left out as well.
+	SkipControllers bool
+	// IncludeUnreferenced also scans the lamport clock window (MinLC and MaxLC) for DID document transactions of the
+	// relevant DIDs that aren't reachable from their current source transactions (e.g. a branch that lost), and adds
+	// them to the graph with the note "unreferenced". It reads every transaction in the window, so it's expensive.
+	IncludeUnreferenced bool
+	// MaxNodes aborts the analysis with ErrTooManyNodes once the graph contains more transactions than this, to protect
+	// against DIDs with enormous histories. It's checked while traversing, before the window is applied. 0 means no limit.
+	MaxNodes int
+	// FirstParent only follows the first previous transaction of every transaction, like git log --first-parent.
+	// Transactions with multiple previous transactions (merges) then only continue the history along one branch, which
+	// keeps the graph linear but leaves out the versions that were merged in. Since only DID document transactions of
+	// the relevant DIDs are followed, the history ends at a first previous transaction that isn't one.
+	FirstParent bool
+	// Progress is called with the number of nodes and edges discovered so far, every time a node is added
+	Progress func(nodes int, edges int)
+}
+
+// inWindow returns whether the given lamport clock falls within the window configured by MinLC and MaxLC
+func (o Options) inWindow(lc uint32) bool {
+	return lc >= o.MinLC && (o.MaxLC == 0 || lc <= o.MaxLC)
+}
+
+// NewDIDDocumentGraphAnalyzer creates an analyzer that resolves DIDs using the given VDR client and reads transactions
+// using the given network client. Both are typically created using the NewClient functions of the nuts-node API packages.
+// The given request editors are applied (in order) to every request, after the editors configured on the clients.
+func NewDIDDocumentGraphAnalyzer(vdr VDRClient, network NetworkClient, editors ...RequestEditorFn) *DIDDocumentGraphAnalyzer {
+	return &DIDDocumentGraphAnalyzer{
+		vdr:     vdr,
+		network: network,
+		editors: editors,
+	}
 }
 
-type node struct {
-	tx    hash.SHA256Hash
-	did   string
-	notes []string
-	lc    uint32
+func (a DIDDocumentGraphAnalyzer) vdrEditors() []vdrAPI.RequestEditorFn {
+	var result []vdrAPI.RequestEditorFn
+	for _, editor := range a.editors {
+		result = append(result, vdrAPI.RequestEditorFn(editor))
+	}
+	return result
+}
+
+func (a DIDDocumentGraphAnalyzer) networkEditors() []networkAPI.RequestEditorFn {
+	var result []networkAPI.RequestEditorFn
+	for _, editor := range a.editors {
+		result = append(result, networkAPI.RequestEditorFn(editor))
+	}
+	return result
 }
 
-// Analyze renders a dotviz diagram of the DID, which contains all relevant transactions.
+// Analyze builds the graph of the DID, which contains all relevant transactions.
 // You can specify multiple DIDs and/or transaction references (these need to be DID documents, however).
 // Limitations:
 // - It does not take into account controllers-of-controllers (only the first level is analyzed)
-func (a DIDDocumentGraphAnalyzer) Analyze(ctx context.Context, didOrTXs []string) (string, error) {
-	//
+func (a DIDDocumentGraphAnalyzer) Analyze(ctx context.Context, didOrTXs []string, options Options) (*Graph, error) {
+	// Inputs can overlap (e.g. the same DID twice, or a DID and one of its own source TXs), so TXs and DIDs are only
+	// collected once to avoid analyzing them more than once.
 	var txsToAnalyze []hash.SHA256Hash
 	var relevantDIDs []string
+	seenTXs := make(map[hash.SHA256Hash]bool)
+	seenDIDs := make(map[string]bool)
+	// The DIDs of which the current version was resolved
+	resolvedDIDs := make(map[string]bool)
+	addTX := func(txRef hash.SHA256Hash) {
+		if !seenTXs[txRef] {
+			seenTXs[txRef] = true
+			txsToAnalyze = append(txsToAnalyze, txRef)
+		}
+	}
+	addDID := func(id string) {
+		if !seenDIDs[id] {
+			seenDIDs[id] = true
+			relevantDIDs = append(relevantDIDs, id)
+		}
+	}
+	addControllers := func(controllers []did.DID) {
+		if options.SkipControllers {
+			return
+		}
+		for _, controller := range controllers {
+			addDID(controller.String())
+		}
+	}
+	seenInputs := make(map[string]bool)
 	for _, didOrTX := range didOrTXs {
+		if seenInputs[didOrTX] {
+			continue
+		}
+		seenInputs[didOrTX] = true
 		if strings.HasPrefix(didOrTX, "did:nuts:") {
-			httpResponse, err := a.VDR.GetDID(ctx, didOrTX, &vdrAPI.GetDIDParams{})
+			resolved, err := a.resolveDID(ctx, didOrTX)
 			if err != nil {
-				return "", fmt.Errorf("failed to get DID document: %w", err)
+				return nil, err
 			}
-			response, err := vdrAPI.ParseGetDIDResponse(httpResponse)
-			if err != nil {
-				return "", fmt.Errorf("failed to parse GetDID response: %w", err)
-			}
-			if response.JSON200 == nil {
-				return "", fmt.Errorf("no DID document found (status=%d)", response.StatusCode())
+			for _, txRef := range resolved.DocumentMetadata.SourceTransactions {
+				addTX(txRef)
 			}
-			txsToAnalyze = append(txsToAnalyze, response.JSON200.DocumentMetadata.SourceTransactions...)
-			relevantDIDs = append(relevantDIDs, didOrTX)
+			addDID(didOrTX)
+			resolvedDIDs[didOrTX] = true
 			// We're interested in the controllers as well
-			for _, controller := range response.JSON200.Document.Controller {
-				relevantDIDs = append(relevantDIDs, controller.String())
-			}
+			addControllers(resolved.Document.Controller)
 		} else {
 			txRef, err := hash.ParseHex(didOrTX)
 			if err != nil {
-				return "", fmt.Errorf("invalid TX reference: %w", err)
+				return nil, fmt.Errorf("invalid TX reference: %w", err)
 			}
-			_, document, err := a.readDIDDocument(ctx, txRef)
+			_, document, _, err := a.readDIDDocument(ctx, txRef, options.TransactionTimeout)
 			if err != nil {
-				return "", fmt.Errorf("failed to read DID document (tx=%s): %w", txRef, err)
+				return nil, fmt.Errorf("failed to read DID document (tx=%s): %w", txRef, err)
 			}
 			if document == nil {
-				return "", fmt.Errorf("specified TX %s does not contain a DID document", txRef)
+				return nil, fmt.Errorf("specified TX %s does not contain a DID document", txRef)
 			}
-			txsToAnalyze = append(txsToAnalyze, txRef)
-			relevantDIDs = append(relevantDIDs, document.ID.String())
+			addTX(txRef)
+			addDID(document.ID.String())
 			// We're interested in the controllers as well
-			for _, controller := range document.Controller {
-				relevantDIDs = append(relevantDIDs, controller.String())
+			addControllers(document.Controller)
+			// The transaction can be an old version, so also include the controllers of the current version: their
+			// transactions are part of the history as well, even though they were added later. The transaction itself
+			// is what was asked for, so if the current version can't be resolved there are just no extra controllers.
+			if !resolvedDIDs[document.ID.String()] && !options.SkipControllers {
+				resolvedDIDs[document.ID.String()] = true
+				if resolved, err := a.resolveDID(ctx, document.ID.String()); err == nil {
+					addControllers(resolved.Document.Controller)
+				}
 			}
 		}
 	}
 
-	edges := make(map[hash.SHA256Hash]map[hash.SHA256Hash]bool, 0)
-	nodes := make(map[hash.SHA256Hash]node, 0)
+	graph := newGraph()
 
 	// Get the DID and all source TXs, these can be related (previous versions) or unrelated (the last TX of the DAG at that time);
 	// we are only interested in the related TXs. We do this by checking whether the source TX is a related DID document,
 	// meaning it has the correct content type and the DID inside it is either the document itself or (one of its) controllers.
 	for _, txRef := range txsToAnalyze {
-		err := a.analyze(ctx, hash.EmptyHash(), txRef, &relevantDIDs, edges, nodes)
+		err := a.analyze(ctx, hash.EmptyHash(), txRef, &relevantDIDs, options, graph)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 	}
-
-	var lines []string
-	lines = append(lines, "digraph {")
-	for _, curr := range nodes {
-		var label []string
-		label = append(label, fmt.Sprintf("label=\"%s", curr.tx))
-		label = append(label, curr.did)
-		label = append(label, fmt.Sprintf("LC=%d", curr.lc))
-		if len(curr.notes) > 0 {
-			label = append(label, strings.Join(curr.notes, ","))
+	if options.IncludeUnreferenced {
+		if err := a.addUnreferenced(ctx, relevantDIDs, options, graph); err != nil {
+			return nil, err
 		}
-		lines = append(lines, fmt.Sprintf(`	node_%s [%s"]`, curr.tx, strings.Join(label, `\n`)))
 	}
-	for left, rights := range edges {
-		for right, _ := range rights {
-			lines = append(lines, fmt.Sprintf(`	node_%s -> node_%s`, left, right))
+	// Before leaving anything out, since that could make unrelated updates appear to share a previous version
+	graph.markConflicts()
+	if options.StopAtDeactivation {
+		graph.pruneAfterDeactivation()
+	}
+	if !options.AsOf.Empty() {
+		if _, exists := graph.Nodes[options.AsOf]; !exists {
+			return nil, fmt.Errorf("transaction %s is not part of the analyzed history", options.AsOf)
 		}
+		graph.limitToHistoryOf(options.AsOf)
+	}
+	graph.limitToWindow(options)
+	return graph, nil
+}
+
+// resolveDID resolves the current version of the given DID document using the VDR
+func (a DIDDocumentGraphAnalyzer) resolveDID(ctx context.Context, id string) (*vdrAPI.DIDResolutionResult, error) {
+	httpResponse, err := a.vdr.GetDID(ctx, id, &vdrAPI.GetDIDParams{}, a.vdrEditors()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get DID document: %w", err)
+	}
+	response, err := vdrAPI.ParseGetDIDResponse(httpResponse)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse GetDID response: %w", err)
+	}
+	if response.JSON200 == nil {
+		return nil, fmt.Errorf("no DID document found (status=%d)", response.StatusCode())
 	}
-	lines = append(lines, "}")
-	return strings.Join(lines, "\n"), nil
+	return response.JSON200, nil
 }
 
-func (a DIDDocumentGraphAnalyzer) analyze(ctx context.Context, referredBy hash.SHA256Hash, txRef hash.SHA256Hash, relevantDIDs *[]string, edges map[hash.SHA256Hash]map[hash.SHA256Hash]bool, nodes map[hash.SHA256Hash]node) error {
+func (a DIDDocumentGraphAnalyzer) analyze(ctx context.Context, referredBy hash.SHA256Hash, txRef hash.SHA256Hash, relevantDIDs *[]string, options Options, graph *Graph) error {
 	// 1. Check if sourceTX is a DID document
 	// 2. If so, check if it's the same DID or one of the controllers
 	// If both are true, add it to the list and proceed to analyze
-	tx, document, err := a.readDIDDocument(ctx, txRef)
+	tx, document, payload, err := a.readDIDDocument(ctx, txRef, options.TransactionTimeout)
 	if err != nil {
 		return fmt.Errorf("failed to read DID document (tx=%s): %w", txRef, err)
 	}
@@ -119,79 +251,195 @@ func (a DIDDocumentGraphAnalyzer) analyze(ctx context.Context, referredBy hash.S
 		// TX does not contain a DID document
 		return nil
 	}
-	// Same DID or one of the controllers?
-	relevant := false
-	for _, curr := range *relevantDIDs {
-		if curr == document.ID.String() {
-			relevant = true
-			break
-		}
-		for _, controller := range document.Controller {
-			if curr == controller.String() {
-				relevant = true
-				break
-			}
-		}
-	}
-	if !relevant {
+	if !isRelevant(document, *relevantDIDs, options.SkipControllers) {
 		return nil
 	}
 
 	// Register the TX
-	n := node{
-		tx:  txRef,
-		did: document.ID.String(),
-		lc:  tx.Clock(),
-	}
-	if tx.SigningKey() != nil {
-		n.notes = append(n.notes, "created")
-	} else if tx.SigningKeyID() != "" {
-		n.notes = append(n.notes, "update")
-	}
-	if len(document.Controller) == 0 && len(document.VerificationMethod) == 0 {
-		n.notes = append(n.notes, "deactivated")
+	n := &Node{
+		Transaction:  txRef,
+		DID:          document.ID.String(),
+		LamportClock: tx.Clock(),
+		Document:     payload,
+		Notes:        Classify(tx, document),
+		Root:         len(tx.Previous()) == 0,
+		SigningKey:   signingKeyDescription(tx),
 	}
 
-	nodes[txRef] = n
+	graph.Nodes[txRef] = n
+	if options.MaxNodes > 0 && len(graph.Nodes) > options.MaxNodes {
+		return fmt.Errorf("%w: more than %d", ErrTooManyNodes, options.MaxNodes)
+	}
 
 	// Register edge
 	if !referredBy.Empty() {
-		rights := edges[txRef]
+		rights := graph.Edges[txRef]
 		if rights == nil {
 			rights = make(map[hash.SHA256Hash]bool, 0)
 		}
 		rights[referredBy] = true
-		edges[txRef] = rights
+		graph.Edges[txRef] = rights
+	}
+	if options.Progress != nil {
+		options.Progress(len(graph.Nodes), graph.EdgeCount())
 	}
 
-	for _, prev := range tx.Previous() {
-		err := a.analyze(ctx, txRef, prev, relevantDIDs, edges, nodes)
-		if err != nil {
+	// History before the window is of no interest
+	if tx.Clock() < options.MinLC {
+		return nil
+	}
+	previous := tx.Previous()
+	if options.FirstParent && len(previous) > 1 {
+		previous = previous[:1]
+	}
+	for _, prev := range previous {
+		err := a.analyze(ctx, txRef, prev, relevantDIDs, options, graph)
+		if errors.Is(err, ErrTooManyNodes) {
+			// Not specific to this transaction, don't wrap it for every level of the traversal
+			return err
+		} else if err != nil {
 			return fmt.Errorf("failed to analyze transaction (tx=%s): %w", tx, err)
 		}
 	}
 	return nil
 }
 
-// readDIDDocument reads the DID document from the given transaction. If the given transaction is not a DID document, it returns nil.
-func (a DIDDocumentGraphAnalyzer) readDIDDocument(ctx context.Context, txRef hash.SHA256Hash) (dag.Transaction, *did.Document, error) {
-	tx, payload, err := a.getTX(ctx, txRef)
+// isRelevant returns whether the given DID document is one of the given DIDs, or is controlled by one of them (unless
+// skipControlled is set)
+func isRelevant(document *did.Document, relevantDIDs []string, skipControlled bool) bool {
+	for _, curr := range relevantDIDs {
+		if curr == document.ID.String() {
+			return true
+		}
+		if skipControlled {
+			continue
+		}
+		for _, controller := range document.Controller {
+			if curr == controller.String() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// addUnreferenced adds the DID document transactions of the relevant DIDs in the lamport clock window of the options
+// that aren't in the graph yet, marking them as unreferenced. They're connected to the transactions in the graph they
+// refer to.
+func (a DIDDocumentGraphAnalyzer) addUnreferenced(ctx context.Context, relevantDIDs []string, options Options, graph *Graph) error {
+	start := int(options.MinLC)
+	params := &networkAPI.ListTransactionsParams{Start: &start}
+	if options.MaxLC > 0 {
+		end := int(options.MaxLC) + 1
+		params.End = &end
+	}
+	httpResponse, err := a.network.ListTransactions(ctx, params, a.networkEditors()...)
+	if err != nil {
+		return fmt.Errorf("failed to list transactions: %w", err)
+	}
+	data, err := io.ReadAll(httpResponse.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read HTTP response: %w", err)
+	}
+	var rawTransactions []string
+	if err := json.Unmarshal(data, &rawTransactions); err != nil {
+		return fmt.Errorf("failed to parse transaction list: %w", err)
+	}
+	// Add them in lamport clock order, so unreferenced transactions referring to each other get connected
+	var candidates []dag.Transaction
+	for _, rawTransaction := range rawTransactions {
+		tx, err := dag.ParseTransaction([]byte(rawTransaction))
+		if err != nil || tx.PayloadType() != "application/did+json" {
+			continue
+		}
+		if _, exists := graph.Nodes[tx.Ref()]; !exists {
+			candidates = append(candidates, tx)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Clock() < candidates[j].Clock()
+	})
+	for _, candidate := range candidates {
+		_, document, payload, err := a.readDIDDocument(ctx, candidate.Ref(), options.TransactionTimeout)
+		if err != nil {
+			return fmt.Errorf("failed to read DID document (tx=%s): %w", candidate.Ref(), err)
+		}
+		if !isRelevant(document, relevantDIDs, options.SkipControllers) {
+			continue
+		}
+		graph.Nodes[candidate.Ref()] = &Node{
+			Transaction:  candidate.Ref(),
+			DID:          document.ID.String(),
+			LamportClock: candidate.Clock(),
+			Document:     payload,
+			Notes:        append(Classify(candidate, document), "unreferenced"),
+			Root:         len(candidate.Previous()) == 0,
+			SigningKey:   signingKeyDescription(candidate),
+		}
+		for _, prev := range candidate.Previous() {
+			if _, exists := graph.Nodes[prev]; !exists {
+				continue
+			}
+			if graph.Edges[prev] == nil {
+				graph.Edges[prev] = make(map[hash.SHA256Hash]bool)
+			}
+			graph.Edges[prev][candidate.Ref()] = true
+		}
+		if options.MaxNodes > 0 && len(graph.Nodes) > options.MaxNodes {
+			return fmt.Errorf("%w: more than %d", ErrTooManyNodes, options.MaxNodes)
+		}
+	}
+	return nil
+}
+
+// signingKeyDescription describes the key that signed the given transaction (see Node.SigningKey)
+func signingKeyDescription(tx dag.Transaction) string {
+	if tx.SigningKey() != nil {
+		return "embedded " + tx.SigningKey().KeyID()
+	}
+	return tx.SigningKeyID()
+}
+
+// Classify describes what the given transaction did to the DID document it contains: created, update and/or deactivated
+func Classify(tx dag.Transaction, document *did.Document) []string {
+	var notes []string
+	if tx.SigningKey() != nil {
+		notes = append(notes, "created")
+	} else if tx.SigningKeyID() != "" {
+		notes = append(notes, "update")
+	}
+	if len(document.Controller) == 0 && len(document.VerificationMethod) == 0 {
+		notes = append(notes, "deactivated")
+	}
+	return notes
+}
+
+// readDIDDocument reads the DID document from the given transaction, also returning the raw payload it was read from.
+// If the given transaction is not a DID document, it returns nil.
+func (a DIDDocumentGraphAnalyzer) readDIDDocument(ctx context.Context, txRef hash.SHA256Hash, timeout time.Duration) (dag.Transaction, *did.Document, []byte, error) {
+	tx, payload, err := a.getTX(ctx, txRef, timeout)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 	// DID document?
 	if tx.PayloadType() != "application/did+json" {
-		return nil, nil, nil
+		return nil, nil, nil, nil
 	}
 	document := &did.Document{}
 	if err := json.Unmarshal(payload, document); err != nil {
-		return nil, nil, fmt.Errorf("failed to unmarshal DID document: %w", err)
+		return nil, nil, nil, fmt.Errorf("failed to unmarshal DID document: %w", err)
 	}
-	return tx, document, nil
+	return tx, document, payload, nil
 }
 
-func (a DIDDocumentGraphAnalyzer) getTX(ctx context.Context, txRef hash.SHA256Hash) (dag.Transaction, []byte, error) {
-	httpResponse, err := a.Network.GetTransaction(ctx, txRef.String())
+// getTX reads the transaction and its payload, taking at most the given timeout (if not 0)
+func (a DIDDocumentGraphAnalyzer) getTX(ctx context.Context, txRef hash.SHA256Hash, timeout time.Duration) (dag.Transaction, []byte, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	httpResponse, err := a.network.GetTransaction(ctx, txRef.String(), a.networkEditors()...)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to get transaction: %w", err)
 	}
@@ -203,7 +451,7 @@ func (a DIDDocumentGraphAnalyzer) getTX(ctx context.Context, txRef hash.SHA256Ha
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to parse transaction: %w", err)
 	}
-	httpResponse, err = a.Network.GetTransactionPayload(ctx, txRef.String())
+	httpResponse, err = a.network.GetTransactionPayload(ctx, txRef.String(), a.networkEditors()...)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to get transaction payload: %w", err)
 	}
@@ -0,0 +1,361 @@
+package analyzers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/nuts-foundation/nuts-node/crypto/hash"
+	networkAPI "github.com/nuts-foundation/nuts-node/network/api/v1"
+	"github.com/nuts-foundation/nuts-node/network/dag"
+	vdrAPI "github.com/nuts-foundation/nuts-node/vdr/api/v1"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testDAG is an in-memory DAG of DID document transactions, which stubs both the VDR and the network API of a node
+type testDAG struct {
+	transactions []dag.Transaction
+	payloads     map[hash.SHA256Hash][]byte
+	dids         map[hash.SHA256Hash]string
+	// names of the transactions, for assertions (see assertGraph)
+	names map[hash.SHA256Hash]string
+	// unresolvable DIDs fail to resolve, like DIDs the VDR doesn't know (anymore)
+	unresolvable map[string]bool
+	// requested are the transactions that were read (with or without payload)
+	requested map[hash.SHA256Hash]bool
+}
+
+func newTestDAG() *testDAG {
+	return &testDAG{
+		payloads:     make(map[hash.SHA256Hash][]byte),
+		dids:         make(map[hash.SHA256Hash]string),
+		names:        make(map[hash.SHA256Hash]string),
+		unresolvable: make(map[string]bool),
+		requested:    make(map[hash.SHA256Hash]bool),
+	}
+}
+
+// add adds a transaction with the given name containing the given DID document (see didDocument), referring to the
+// given previous transactions. A transaction that creates a DID document embeds its signing key, updates refer to it.
+func (d *testDAG) add(name string, document string, create bool, prevs ...dag.Transaction) dag.Transaction {
+	tx := dag.CreateSignedTestTransaction(uint32(len(d.transactions)+1), time.Now(), nil, "application/did+json", create, prevs...)
+	var parsed struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal([]byte(document), &parsed); err != nil {
+		panic(err)
+	}
+	d.transactions = append(d.transactions, tx)
+	d.payloads[tx.Ref()] = []byte(document)
+	d.dids[tx.Ref()] = parsed.ID
+	d.names[tx.Ref()] = name
+	return tx
+}
+
+// didDocument returns a DID document with the given ID and controllers, which has a key of its own if withKey is set.
+// A DID document without key and controllers is deactivated.
+func didDocument(id string, withKey bool, controllers ...string) string {
+	document := map[string]interface{}{
+		"@context": "https://www.w3.org/ns/did/v1",
+		"id":       id,
+	}
+	if len(controllers) > 0 {
+		document["controller"] = controllers
+	}
+	if withKey {
+		document["verificationMethod"] = []map[string]interface{}{{
+			"id":           id + "#key-1",
+			"type":         "JsonWebKey2020",
+			"controller":   id,
+			"publicKeyJwk": map[string]string{"kty": "EC", "crv": "P-256", "x": "x", "y": "y"},
+		}}
+	}
+	data, _ := json.Marshal(document)
+	return string(data)
+}
+
+// response returns an HTTP response with the given status, content type and body
+func response(status int, contentType string, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{contentType}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+}
+
+// GetDID resolves the current version of the DID document: the last transaction of the DID that was added
+func (d *testDAG) GetDID(_ context.Context, id string, _ *vdrAPI.GetDIDParams, _ ...vdrAPI.RequestEditorFn) (*http.Response, error) {
+	var current dag.Transaction
+	for _, tx := range d.transactions {
+		if d.dids[tx.Ref()] == id {
+			current = tx
+		}
+	}
+	if current == nil || d.unresolvable[id] {
+		return response(http.StatusNotFound, "application/problem+json", `{"status":404,"title":"unable to find the DID document"}`), nil
+	}
+	body := fmt.Sprintf(`{"document":%s,"documentMetadata":{"txs":["%s"]}}`, d.payloads[current.Ref()], current.Ref())
+	return response(http.StatusOK, "application/json", body), nil
+}
+
+// transaction returns the transaction with the given reference and registers it as requested, or nil if it's unknown
+func (d *testDAG) transaction(ref string) dag.Transaction {
+	for _, tx := range d.transactions {
+		if tx.Ref().String() == ref {
+			d.requested[tx.Ref()] = true
+			return tx
+		}
+	}
+	return nil
+}
+
+func (d *testDAG) GetTransaction(_ context.Context, ref string, _ ...networkAPI.RequestEditorFn) (*http.Response, error) {
+	tx := d.transaction(ref)
+	if tx == nil {
+		return response(http.StatusNotFound, "application/problem+json", `{"status":404}`), nil
+	}
+	return response(http.StatusOK, "application/jose", string(tx.Data())), nil
+}
+
+func (d *testDAG) GetTransactionPayload(_ context.Context, ref string, _ ...networkAPI.RequestEditorFn) (*http.Response, error) {
+	tx := d.transaction(ref)
+	if tx == nil {
+		return response(http.StatusNotFound, "application/problem+json", `{"status":404}`), nil
+	}
+	return response(http.StatusOK, "application/octet-stream", string(d.payloads[tx.Ref()])), nil
+}
+
+func (d *testDAG) ListTransactions(_ context.Context, params *networkAPI.ListTransactionsParams, _ ...networkAPI.RequestEditorFn) (*http.Response, error) {
+	result := []string{}
+	for _, tx := range d.transactions {
+		if params.Start != nil && int(tx.Clock()) < *params.Start {
+			continue
+		}
+		if params.End != nil && int(tx.Clock()) >= *params.End {
+			continue
+		}
+		result = append(result, string(tx.Data()))
+	}
+	data, _ := json.Marshal(result)
+	return response(http.StatusOK, "application/json", string(data)), nil
+}
+
+// node returns the node of the transaction with the given name in the given graph, failing the test if it isn't there
+func (d *testDAG) node(t *testing.T, graph *Graph, name string) *Node {
+	t.Helper()
+	for ref, node := range graph.Nodes {
+		if d.names[ref] == name {
+			return node
+		}
+	}
+	t.Fatalf("expected %s to be in the graph", name)
+	return nil
+}
+
+// analyze analyzes the given DIDs and/or transactions of the DAG, failing the test on error
+func (d *testDAG) analyze(t *testing.T, options Options, didOrTXs ...string) *Graph {
+	t.Helper()
+	graph, err := NewDIDDocumentGraphAnalyzer(d, d).Analyze(context.Background(), didOrTXs, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return graph
+}
+
+func TestDIDDocumentGraphAnalyzer_Analyze_TransactionInput(t *testing.T) {
+	// A was controlled by B, which refers to C. The current version of A is controlled by C instead.
+	newDAG := func() (*testDAG, dag.Transaction) {
+		d := newTestDAG()
+		c := d.add("C", didDocument("did:nuts:C", true), true)
+		b := d.add("B", didDocument("did:nuts:B", true), true, c)
+		a1 := d.add("A1", didDocument("did:nuts:A", true, "did:nuts:B"), true, b)
+		d.add("A2", didDocument("did:nuts:A", true, "did:nuts:C"), false, a1)
+		return d, a1
+	}
+	t.Run("controllers of the current version are included", func(t *testing.T) {
+		d, a1 := newDAG()
+
+		graph := d.analyze(t, Options{}, a1.Ref().String())
+
+		assertGraph(t, graph, d.names, []string{"A1", "B", "C"}, []string{"B->A1", "C->B"})
+	})
+	t.Run("current version can't be resolved", func(t *testing.T) {
+		d, a1 := newDAG()
+		d.unresolvable["did:nuts:A"] = true
+
+		graph := d.analyze(t, Options{}, a1.Ref().String())
+
+		// Only the controllers of the transaction itself are known
+		assertGraph(t, graph, d.names, []string{"A1", "B"}, []string{"B->A1"})
+	})
+}
+
+func TestDIDDocumentGraphAnalyzer_Analyze_Since(t *testing.T) {
+	// A DID document that's updated at every lamport clock, 0 to 3
+	d := newTestDAG()
+	tx := d.add("0", didDocument("did:nuts:A", true), true)
+	for i := 1; i <= 3; i++ {
+		tx = d.add(fmt.Sprintf("%d", i), didDocument("did:nuts:A", true), false, tx)
+	}
+
+	// -since only sets the start of the window (MinLC)
+	graph := d.analyze(t, Options{MinLC: 2}, "did:nuts:A")
+
+	assertGraph(t, graph, d.names, []string{"1", "2", "3"}, []string{"1->2", "2->3"})
+	older := d.node(t, graph, "1")
+	if !older.Boundary || !hasNote(older, "older") {
+		t.Errorf("expected the version before the window to be a boundary noted as older, got %v", older.Notes)
+	}
+	for _, name := range []string{"2", "3"} {
+		if node := d.node(t, graph, name); node.Boundary || hasNote(node, "older") || hasNote(node, "newer") {
+			t.Errorf("expected %s not to be a boundary, got %v", name, node.Notes)
+		}
+	}
+}
+
+func TestDIDDocumentGraphAnalyzer_Analyze_FirstParent(t *testing.T) {
+	// Concurrent updates (left and right) of the same version, merged by a later update
+	d := newTestDAG()
+	create := d.add("create", didDocument("did:nuts:A", true), true)
+	left := d.add("left", didDocument("did:nuts:A", true), false, create)
+	right := d.add("right", didDocument("did:nuts:A", true), false, create)
+	d.add("merge", didDocument("did:nuts:A", true), false, left, right)
+
+	t.Run("full traversal", func(t *testing.T) {
+		graph := d.analyze(t, Options{}, "did:nuts:A")
+
+		assertGraph(t, graph, d.names, []string{"create", "left", "right", "merge"},
+			[]string{"create->left", "create->right", "left->merge", "right->merge"})
+	})
+	t.Run("first parent", func(t *testing.T) {
+		graph := d.analyze(t, Options{FirstParent: true}, "did:nuts:A")
+
+		// The branch that was merged in is left out
+		assertGraph(t, graph, d.names, []string{"create", "left", "merge"}, []string{"create->left", "left->merge"})
+	})
+}
+
+func TestDIDDocumentGraphAnalyzer_Analyze_SkipControllers(t *testing.T) {
+	// A is controlled by B, the update of A refers to its previous version and to an update of B. A controls C.
+	newDAG := func() *testDAG {
+		d := newTestDAG()
+		b0 := d.add("B0", didDocument("did:nuts:B", true), true)
+		b1 := d.add("B1", didDocument("did:nuts:B", true), false, b0)
+		a0 := d.add("A0", didDocument("did:nuts:A", false, "did:nuts:B"), true)
+		c := d.add("C", didDocument("did:nuts:C", false, "did:nuts:A"), true, a0)
+		d.add("A1", didDocument("did:nuts:A", false, "did:nuts:B"), false, a0, b1, c)
+		return d
+	}
+
+	t.Run("with controllers", func(t *testing.T) {
+		d := newDAG()
+
+		graph := d.analyze(t, Options{}, "did:nuts:A")
+
+		assertGraph(t, graph, d.names, []string{"B0", "B1", "A0", "C", "A1"}, []string{"B0->B1", "A0->C", "A0->A1", "B1->A1", "C->A1"})
+	})
+	t.Run("skip controllers", func(t *testing.T) {
+		d := newDAG()
+
+		graph := d.analyze(t, Options{SkipControllers: true}, "did:nuts:A")
+
+		assertGraph(t, graph, d.names, []string{"A0", "A1"}, []string{"A0->A1"})
+		// B1 is read to find out it's not a version of A, but the history of B isn't followed
+		for ref, name := range d.names {
+			if name == "B0" && d.requested[ref] {
+				t.Error("expected the history of the controller not to be read")
+			}
+		}
+	})
+}
+
+func TestDIDDocumentGraphAnalyzer_Analyze_DuplicateInput(t *testing.T) {
+	d := newTestDAG()
+	b := d.add("B", didDocument("did:nuts:B", true), true)
+	a0 := d.add("A0", didDocument("did:nuts:A", false, "did:nuts:B"), true, b)
+	a1 := d.add("A1", didDocument("did:nuts:A", false, "did:nuts:B"), false, a0)
+	// The nodes of a graph are rendered in random order
+	render := func(graph *Graph) string {
+		lines := strings.Split(graph.Dot(RenderOptions{}), "\n")
+		sort.Strings(lines)
+		return strings.Join(lines, "\n")
+	}
+	testCases := []struct {
+		name       string
+		input      []string
+		duplicated []string
+	}{
+		{"DID", []string{"did:nuts:A"}, []string{"did:nuts:A", "did:nuts:A"}},
+		{"TX", []string{a0.Ref().String()}, []string{a0.Ref().String(), a0.Ref().String()}},
+		{"DID and its current TX", []string{"did:nuts:A"}, []string{"did:nuts:A", a1.Ref().String()}},
+		{"DID and its controller", []string{"did:nuts:A", "did:nuts:B"}, []string{"did:nuts:A", "did:nuts:B", "did:nuts:A", "did:nuts:B"}},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			expected := render(d.analyze(t, Options{}, testCase.input...))
+
+			actual := render(d.analyze(t, Options{}, testCase.duplicated...))
+
+			if actual != expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", expected, actual)
+			}
+		})
+	}
+}
+
+// serve serves the network API of the DAG over HTTP, stalling requests for the given transaction until the client
+// gives up
+func (d *testDAG) serve(t *testing.T, stalled hash.SHA256Hash) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ref := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/internal/network/v1/transaction/"), "/payload")
+		if ref == stalled.String() {
+			<-r.Context().Done()
+			return
+		}
+		var stubbed *http.Response
+		if strings.HasSuffix(r.URL.Path, "/payload") {
+			stubbed, _ = d.GetTransactionPayload(r.Context(), ref)
+		} else {
+			stubbed, _ = d.GetTransaction(r.Context(), ref)
+		}
+		w.Header().Set("Content-Type", stubbed.Header.Get("Content-Type"))
+		w.WriteHeader(stubbed.StatusCode)
+		_, _ = io.Copy(w, stubbed.Body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDIDDocumentGraphAnalyzer_Analyze_TransactionTimeout(t *testing.T) {
+	// Reading the previous version of A stalls
+	d := newTestDAG()
+	a0 := d.add("A0", didDocument("did:nuts:A", true), true)
+	d.add("A1", didDocument("did:nuts:A", true), false, a0)
+	server := d.serve(t, a0.Ref())
+	networkClient, err := networkAPI.NewClient(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	analyzer := NewDIDDocumentGraphAnalyzer(d, networkClient)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+
+	_, err = analyzer.Analyze(ctx, []string{"did:nuts:A"}, Options{TransactionTimeout: 100 * time.Millisecond})
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the transaction to time out, got %v", err)
+	}
+	if ctx.Err() != nil {
+		t.Errorf("expected the transaction to fail fast, took %s", time.Since(start))
+	}
+	if !strings.Contains(err.Error(), a0.Ref().String()) {
+		t.Errorf("expected the error to name the stalled transaction, got %v", err)
+	}
+}
@@ -0,0 +1,13 @@
+// Package analyzers analyzes the transactions of a nuts network, and can be embedded in other Go programs.
+//
+// For example, to render the history of a DID document as dotviz diagram:
+//
+//	vdrClient, _ := vdrAPI.NewClient("http://localhost:1323")
+//	networkClient, _ := networkAPI.NewClient("http://localhost:1323")
+//	analyzer := analyzers.NewDIDDocumentGraphAnalyzer(vdrClient, networkClient)
+//	graph, err := analyzer.Analyze(ctx, []string{"did:nuts:123"}, analyzers.Options{})
+//	if err != nil {
+//		return err
+//	}
+//	fmt.Println(graph.Dot(analyzers.RenderOptions{}))
+package analyzers
@@ -0,0 +1,110 @@
+package analyzers_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/nuts-foundation/data-viewer/analyzers"
+	networkAPI "github.com/nuts-foundation/nuts-node/network/api/v1"
+	"github.com/nuts-foundation/nuts-node/network/dag"
+	vdrAPI "github.com/nuts-foundation/nuts-node/vdr/api/v1"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"time"
+)
+
+func ExampleNewDIDDocumentGraphAnalyzer() {
+	// A node whose DAG contains a DID document that's controlled by another one, and then updated
+	node := startExampleNode()
+	defer node.Close()
+
+	vdrClient, err := vdrAPI.NewClient(node.URL)
+	if err != nil {
+		panic(err)
+	}
+	networkClient, err := networkAPI.NewClient(node.URL)
+	if err != nil {
+		panic(err)
+	}
+	analyzer := analyzers.NewDIDDocumentGraphAnalyzer(vdrClient, networkClient)
+	graph, err := analyzer.Analyze(context.Background(), []string{"did:nuts:subject"}, analyzers.Options{})
+	if err != nil {
+		panic(err)
+	}
+
+	var nodes []*analyzers.Node
+	for _, node := range graph.Nodes {
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].LamportClock < nodes[j].LamportClock
+	})
+	fmt.Printf("%d transactions, %d edges\n", len(graph.Nodes), graph.EdgeCount())
+	for _, node := range nodes {
+		fmt.Printf("%d %s: %s\n", node.LamportClock, node.DID, strings.Join(node.Notes, ", "))
+	}
+	// Output:
+	// 3 transactions, 2 edges
+	// 0 did:nuts:controller: created
+	// 1 did:nuts:subject: created
+	// 2 did:nuts:subject: update
+}
+
+// startExampleNode starts a server providing the VDR and network API of a nuts node for the example
+func startExampleNode() *httptest.Server {
+	var transactions []dag.Transaction
+	payloads := make(map[string]string)
+	add := func(id string, controller string, create bool, prevs ...dag.Transaction) dag.Transaction {
+		tx := dag.CreateSignedTestTransaction(uint32(len(transactions)+1), time.Now(), nil, "application/did+json", create, prevs...)
+		document := map[string]interface{}{"@context": "https://www.w3.org/ns/did/v1", "id": id}
+		if controller != "" {
+			document["controller"] = []string{controller}
+		} else {
+			document["verificationMethod"] = []map[string]interface{}{{
+				"id":           id + "#key-1",
+				"type":         "JsonWebKey2020",
+				"controller":   id,
+				"publicKeyJwk": map[string]string{"kty": "EC", "crv": "P-256", "x": "x", "y": "y"},
+			}}
+		}
+		payload, _ := json.Marshal(document)
+		transactions = append(transactions, tx)
+		payloads[tx.Ref().String()] = string(payload)
+		return tx
+	}
+	controller := add("did:nuts:controller", "", true)
+	created := add("did:nuts:subject", "did:nuts:controller", true, controller)
+	add("did:nuts:subject", "did:nuts:controller", false, created)
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id := strings.TrimPrefix(r.URL.Path, "/internal/vdr/v1/did/"); id != r.URL.Path {
+			// The current version of a DID document is the last transaction containing it
+			var current dag.Transaction
+			for _, tx := range transactions {
+				if strings.Contains(payloads[tx.Ref().String()], `"id":"`+id+`"`) {
+					current = tx
+				}
+			}
+			if current == nil {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = fmt.Fprintf(w, `{"document":%s,"documentMetadata":{"txs":["%s"]}}`, payloads[current.Ref().String()], current.Ref())
+			return
+		}
+		ref := strings.TrimPrefix(r.URL.Path, "/internal/network/v1/transaction/")
+		for _, tx := range transactions {
+			if ref == tx.Ref().String() {
+				_, _ = w.Write(tx.Data())
+				return
+			} else if ref == tx.Ref().String()+"/payload" {
+				_, _ = w.Write([]byte(payloads[tx.Ref().String()]))
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+}
@@ -0,0 +1,507 @@
+package analyzers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/nuts-foundation/nuts-node/crypto/hash"
+	"hash/fnv"
+	"html"
+	"sort"
+	"strings"
+)
+
+// Graph is the result of an analysis: the relevant transactions (nodes) and how they refer to each other (edges)
+type Graph struct {
+	Nodes map[hash.SHA256Hash]*Node
+	// Edges maps a transaction to the transactions that refer to it as previous transaction
+	Edges map[hash.SHA256Hash]map[hash.SHA256Hash]bool
+}
+
+// Node is a transaction in the graph
+type Node struct {
+	Transaction  hash.SHA256Hash
+	DID          string
+	LamportClock uint32
+	// Notes describe what the transaction did to the DID document (e.g. created, update, deactivated)
+	Notes []string
+	// Document is the DID document (transaction payload) as read from the transaction
+	Document []byte
+	// Boundary indicates the transaction lies outside the lamport clock window of the analysis (see Options), but is
+	// included because it's directly related to a transaction inside the window
+	Boundary bool
+	// Terminal indicates the transaction deactivated the DID document and the transactions following it were left out
+	Terminal bool
+	// Root indicates the transaction has no previous transactions, meaning it's where the DAG begins
+	Root bool
+	// Conflict indicates the transaction updated the DID document concurrently with another transaction: both refer to
+	// the same previous version of the document (see markConflicts)
+	Conflict bool
+	// SigningKey describes the key that signed the transaction: the key ID, prefixed with "embedded " if the key is
+	// embedded in the transaction (when creating a DID document)
+	SigningKey string
+}
+
+// RenderOptions configures how a graph is rendered
+type RenderOptions struct {
+	// Tooltips adds a tooltip containing the DID document to each node, which is shown when hovering over the node in
+	// renderers that support it (e.g. SVG). It's disabled by default, since it bloats the output.
+	Tooltips bool
+	// HTMLLabels renders the fields of each node as a small table using Graphviz HTML-like labels, instead of lines of
+	// plain text. Only supported by Graphviz itself.
+	HTMLLabels bool
+	// NotePerLine puts each note on its own line of the label, instead of joining them with commas on a single line
+	NotePerLine bool
+	// ColorByDID fills each node with a color derived from its DID, so the same DID always gets the same color
+	ColorByDID bool
+	// Palette is the list of colors ColorByDID picks from. If empty, DefaultPalette is used.
+	Palette []string
+	// PlainRoots renders root transactions (see Node.Root) like any other node, instead of with a distinct shape
+	PlainRoots bool
+	// ReverseEdges flips the direction of the edges. By default an edge points from a transaction to the transactions
+	// that refer to it as previous transaction (parent to child, "happened before"). Reversed, edges point from a
+	// transaction to the previous transactions it refers to (child to parent, "references").
+	ReverseEdges bool
+	// SigningKeys adds the key that signed the transaction (see Node.SigningKey) to each node label
+	SigningKeys bool
+}
+
+// DefaultPalette is the colorblind-friendly Okabe-Ito palette (without black)
+var DefaultPalette = []string{"#E69F00", "#56B4E9", "#009E73", "#F0E442", "#0072B2", "#D55E00", "#CC79A7"}
+
+// DIDColor returns the color for the given DID from the given palette (or DefaultPalette if empty). It's derived from
+// a hash of the DID, so it's the same for a given DID and palette.
+func DIDColor(did string, palette []string) string {
+	if len(palette) == 0 {
+		palette = DefaultPalette
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(did))
+	return palette[h.Sum32()%uint32(len(palette))]
+}
+
+func newGraph() *Graph {
+	return &Graph{
+		Nodes: make(map[hash.SHA256Hash]*Node, 0),
+		Edges: make(map[hash.SHA256Hash]map[hash.SHA256Hash]bool, 0),
+	}
+}
+
+// EdgeCount returns the number of edges in the graph
+func (g *Graph) EdgeCount() int {
+	count := 0
+	for _, rights := range g.Edges {
+		count += len(rights)
+	}
+	return count
+}
+
+// markConflicts marks the transactions that updated the same DID document based on the same version as conflicting,
+// adding a "conflict" note. Such concurrent updates fork the history of the DID document. Updates that build on each
+// other (one is an ancestor of the other) aren't concurrent, even if both refer to the same version.
+func (g *Graph) markConflicts() {
+	for _, children := range g.Edges {
+		byDID := make(map[string][]*Node)
+		for child := range children {
+			if node, exists := g.Nodes[child]; exists && hasNote(node, "update") {
+				byDID[node.DID] = append(byDID[node.DID], node)
+			}
+		}
+		for _, nodes := range byDID {
+			for i, node := range nodes {
+				for _, other := range nodes[i+1:] {
+					if g.isAncestor(node.Transaction, other.Transaction) || g.isAncestor(other.Transaction, node.Transaction) {
+						continue
+					}
+					for _, conflicting := range []*Node{node, other} {
+						if !conflicting.Conflict {
+							conflicting.Conflict = true
+							conflicting.Notes = append(conflicting.Notes, "conflict")
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// isAncestor returns whether the given transaction (directly or indirectly) refers to the given ancestor
+func (g *Graph) isAncestor(ancestor hash.SHA256Hash, ref hash.SHA256Hash) bool {
+	visited := make(map[hash.SHA256Hash]bool)
+	queue := []hash.SHA256Hash{ancestor}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for child := range g.Edges[current] {
+			if child == ref {
+				return true
+			}
+			if !visited[child] {
+				visited[child] = true
+				queue = append(queue, child)
+			}
+		}
+	}
+	return false
+}
+
+// pruneAfterDeactivation removes the transactions of a DID document that follow (directly or indirectly) a transaction
+// that deactivated it, and marks the deactivating transactions as terminal. Transactions of other DID documents (e.g.
+// of a controller) that happen to refer to them are kept.
+func (g *Graph) pruneAfterDeactivation() {
+	var queue []hash.SHA256Hash
+	for ref, node := range g.Nodes {
+		if hasNote(node, "deactivated") {
+			node.Terminal = true
+			queue = append(queue, g.childrenOfDID(ref, node.DID)...)
+		}
+	}
+	pruned := make(map[hash.SHA256Hash]bool)
+	for len(queue) > 0 {
+		ref := queue[0]
+		queue = queue[1:]
+		if pruned[ref] {
+			continue
+		}
+		pruned[ref] = true
+		queue = append(queue, g.childrenOfDID(ref, g.Nodes[ref].DID)...)
+	}
+	for ref := range pruned {
+		delete(g.Nodes, ref)
+		delete(g.Edges, ref)
+	}
+	// Drop the edges to pruned transactions from the remaining ones
+	for left, children := range g.Edges {
+		for child := range children {
+			if pruned[child] {
+				delete(children, child)
+			}
+		}
+		if len(children) == 0 {
+			delete(g.Edges, left)
+		}
+	}
+}
+
+// childrenOfDID returns the transactions of the given DID that refer to the given transaction
+func (g *Graph) childrenOfDID(ref hash.SHA256Hash, did string) []hash.SHA256Hash {
+	var result []hash.SHA256Hash
+	for child := range g.Edges[ref] {
+		if node, exists := g.Nodes[child]; exists && node.DID == did {
+			result = append(result, child)
+		}
+	}
+	return result
+}
+
+// hasNote returns whether the given node has the given note
+func hasNote(node *Node, note string) bool {
+	for _, curr := range node.Notes {
+		if curr == note {
+			return true
+		}
+	}
+	return false
+}
+
+// limitToHistoryOf removes all nodes except the given one and the nodes it (directly or indirectly) refers to
+func (g *Graph) limitToHistoryOf(ref hash.SHA256Hash) {
+	parents := make(map[hash.SHA256Hash][]hash.SHA256Hash)
+	for left, rights := range g.Edges {
+		for right := range rights {
+			parents[right] = append(parents[right], left)
+		}
+	}
+	keep := map[hash.SHA256Hash]bool{ref: true}
+	queue := []hash.SHA256Hash{ref}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, parent := range parents[current] {
+			if !keep[parent] {
+				keep[parent] = true
+				queue = append(queue, parent)
+			}
+		}
+	}
+	for curr := range g.Nodes {
+		if !keep[curr] {
+			delete(g.Nodes, curr)
+			delete(g.Edges, curr)
+		}
+	}
+	for _, rights := range g.Edges {
+		for right := range rights {
+			if !keep[right] {
+				delete(rights, right)
+			}
+		}
+	}
+}
+
+// limitToWindow removes the nodes outside the lamport clock window of the given options, except for the ones that are
+// directly connected to a node inside the window: those are kept and marked as boundary.
+func (g *Graph) limitToWindow(options Options) {
+	keep := make(map[hash.SHA256Hash]bool)
+	for ref, node := range g.Nodes {
+		if options.inWindow(node.LamportClock) {
+			keep[ref] = true
+		}
+	}
+	for left, rights := range g.Edges {
+		for right := range rights {
+			if options.inWindow(g.Nodes[left].LamportClock) != options.inWindow(g.Nodes[right].LamportClock) {
+				keep[left] = true
+				keep[right] = true
+			}
+		}
+	}
+	for ref, node := range g.Nodes {
+		if !keep[ref] {
+			delete(g.Nodes, ref)
+			delete(g.Edges, ref)
+		} else if node.LamportClock < options.MinLC {
+			node.Boundary = true
+			node.Notes = append(node.Notes, "older")
+		} else if !options.inWindow(node.LamportClock) {
+			node.Boundary = true
+			node.Notes = append(node.Notes, "newer")
+		}
+	}
+	for left, rights := range g.Edges {
+		for right := range rights {
+			if !keep[right] {
+				delete(rights, right)
+			}
+		}
+		if len(rights) == 0 {
+			delete(g.Edges, left)
+		}
+	}
+}
+
+// Render renders the graph in the given format. Supported formats: dot, plantuml, mermaid and edges
+func (g *Graph) Render(format string, options RenderOptions) (string, error) {
+	switch format {
+	case "dot":
+		return g.Dot(options), nil
+	case "plantuml":
+		return g.PlantUML(options), nil
+	case "edges":
+		return g.Adjacency(), nil
+	case "mermaid":
+		return g.Mermaid(options), nil
+	default:
+		return "", fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+// Adjacency renders only the edges of the graph, as "child parent" pairs of full transaction hashes (one per line,
+// sorted), for scripts. The render options don't apply to it.
+func (g *Graph) Adjacency() string {
+	var lines []string
+	for parent, children := range g.Edges {
+		for child := range children {
+			lines = append(lines, fmt.Sprintf("%s %s", child, parent))
+		}
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+// Dot renders the graph as dotviz diagram
+func (g *Graph) Dot(options RenderOptions) string {
+	var lines []string
+	lines = append(lines, "digraph {")
+	for _, curr := range g.Nodes {
+		var attributes string
+		if options.HTMLLabels {
+			attributes = "label=<" + htmlLabel(curr, options) + ">"
+		} else {
+			attributes = fmt.Sprintf(`label="%s"`, strings.Join(labelLines(curr, options), `\n`))
+		}
+		var style []string
+		if curr.Boundary {
+			style = append(style, "dashed")
+		}
+		if curr.Terminal {
+			attributes += ` peripheries=2`
+		}
+		if curr.Root && !options.PlainRoots {
+			attributes += ` shape=house`
+		}
+		if curr.Conflict {
+			attributes += ` color=red penwidth=2`
+		}
+		if options.ColorByDID {
+			style = append(style, "filled")
+			attributes += fmt.Sprintf(` fillcolor="%s"`, escapeDot(DIDColor(curr.DID, options.Palette)))
+		}
+		if len(style) > 0 {
+			attributes += fmt.Sprintf(` style="%s"`, strings.Join(style, ","))
+		}
+		if options.Tooltips {
+			attributes += fmt.Sprintf(` tooltip="%s"`, escapeDot(indentDocument(curr.Document)))
+		}
+		lines = append(lines, fmt.Sprintf(`	node_%s [%s]`, curr.Transaction, attributes))
+	}
+	for left, rights := range g.Edges {
+		for right := range rights {
+			from, to := edgeDirection(left, right, options)
+			lines = append(lines, fmt.Sprintf(`	node_%s -> node_%s`, from, to))
+		}
+	}
+	lines = append(lines, "}")
+	return strings.Join(lines, "\n")
+}
+
+// PlantUML renders the graph as PlantUML diagram, with a rectangle per node. HTMLLabels and Tooltips don't apply.
+func (g *Graph) PlantUML(options RenderOptions) string {
+	var lines []string
+	lines = append(lines, "@startuml")
+	for _, curr := range g.Nodes {
+		line := fmt.Sprintf(`rectangle "%s" as node_%s`, escapePlantUML(strings.Join(labelLines(curr, options), "\n")), curr.Transaction)
+		if curr.Root && !options.PlainRoots {
+			line += " <<root>>"
+		}
+		var style []string
+		if options.ColorByDID {
+			style = append(style, strings.TrimPrefix(DIDColor(curr.DID, options.Palette), "#"))
+		}
+		if curr.Boundary {
+			style = append(style, "line.dashed")
+		}
+		if curr.Terminal {
+			style = append(style, "line.bold")
+		}
+		if curr.Conflict {
+			style = append(style, "line:red")
+		}
+		if len(style) > 0 {
+			line += " #" + strings.Join(style, ";")
+		}
+		lines = append(lines, line)
+	}
+	for left, rights := range g.Edges {
+		for right := range rights {
+			from, to := edgeDirection(left, right, options)
+			lines = append(lines, fmt.Sprintf(`node_%s --> node_%s`, from, to))
+		}
+	}
+	lines = append(lines, "@enduml")
+	return strings.Join(lines, "\n")
+}
+
+// Mermaid renders the graph as Mermaid flowchart
+func (g *Graph) Mermaid(options RenderOptions) string {
+	var lines []string
+	lines = append(lines, "flowchart TD")
+	for _, curr := range g.Nodes {
+		label := strings.ReplaceAll(strings.Join(labelLines(curr, options), "<br/>"), `"`, "#quot;")
+		if curr.Root && !options.PlainRoots {
+			lines = append(lines, fmt.Sprintf(`    node_%s[/"%s"\]`, curr.Transaction, label))
+		} else {
+			lines = append(lines, fmt.Sprintf(`    node_%s["%s"]`, curr.Transaction, label))
+		}
+		var style []string
+		if options.ColorByDID {
+			style = append(style, "fill:"+DIDColor(curr.DID, options.Palette))
+		}
+		if curr.Boundary {
+			style = append(style, "stroke-dasharray:5 5")
+		}
+		if curr.Terminal {
+			style = append(style, "stroke-width:3px")
+		}
+		if curr.Conflict {
+			style = append(style, "stroke:red")
+		}
+		if len(style) > 0 {
+			lines = append(lines, fmt.Sprintf("    style node_%s %s", curr.Transaction, strings.Join(style, ",")))
+		}
+	}
+	for left, rights := range g.Edges {
+		for right := range rights {
+			from, to := edgeDirection(left, right, options)
+			lines = append(lines, fmt.Sprintf(`    node_%s --> node_%s`, from, to))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// edgeDirection returns the ends of the edge from the given parent to child transaction in the order it's rendered
+func edgeDirection(parent hash.SHA256Hash, child hash.SHA256Hash, options RenderOptions) (hash.SHA256Hash, hash.SHA256Hash) {
+	if options.ReverseEdges {
+		return child, parent
+	}
+	return parent, child
+}
+
+// htmlLabel renders the fields of the given node as a Graphviz HTML-like table
+func htmlLabel(node *Node, options RenderOptions) string {
+	var rows []string
+	for _, field := range labelLines(node, options) {
+		rows = append(rows, "<TR><TD>"+html.EscapeString(field)+"</TD></TR>")
+	}
+	return `<TABLE BORDER="0" CELLBORDER="1" CELLSPACING="0">` + strings.Join(rows, "") + "</TABLE>"
+}
+
+// labelLines returns the lines of the label of the given node: its transaction, DID, lamport clock, signing key (if
+// enabled) and notes
+func labelLines(node *Node, options RenderOptions) []string {
+	lines := []string{node.Transaction.String(), node.DID, fmt.Sprintf("LC=%d", node.LamportClock)}
+	if options.SigningKeys && node.SigningKey != "" {
+		lines = append(lines, "key="+abbreviateKeyID(node.SigningKey))
+	}
+	return append(lines, noteLines(node.Notes, options)...)
+}
+
+// maxKeyIDLength is the length above which key IDs are abbreviated in labels
+const maxKeyIDLength = 48
+
+// abbreviateKeyID shortens long key IDs by eliding the middle of the DID, keeping its start and the fragment (e.g.
+// #key-1), which together still identify the key at a glance
+func abbreviateKeyID(keyID string) string {
+	if len(keyID) <= maxKeyIDLength {
+		return keyID
+	}
+	did, fragment, _ := strings.Cut(keyID, "#")
+	keep := maxKeyIDLength - len(fragment) - 4
+	if keep < 16 {
+		return keyID[:maxKeyIDLength-3] + "..."
+	}
+	return did[:keep] + "...#" + fragment
+}
+
+// noteLines returns the label lines for the given notes: one line per note or a single comma-separated line
+func noteLines(notes []string, options RenderOptions) []string {
+	if len(notes) == 0 {
+		return nil
+	}
+	if options.NotePerLine {
+		return notes
+	}
+	return []string{strings.Join(notes, ",")}
+}
+
+// indentDocument formats the given document as indented JSON, or returns it as-is if it isn't valid JSON
+func indentDocument(document []byte) string {
+	var indented bytes.Buffer
+	if json.Indent(&indented, document, "", "  ") != nil {
+		return string(document)
+	}
+	return indented.String()
+}
+
+// escapePlantUML escapes the given text for use in a double-quoted PlantUML label, which can't contain double quotes
+func escapePlantUML(text string) string {
+	text = strings.ReplaceAll(text, `"`, "'")
+	return strings.ReplaceAll(text, "\n", `\n`)
+}
+
+// escapeDot escapes the given text for use in a double-quoted dot string, preserving line breaks
+func escapeDot(text string) string {
+	text = strings.ReplaceAll(text, `\`, `\\`)
+	text = strings.ReplaceAll(text, `"`, `\"`)
+	return strings.ReplaceAll(text, "\n", `\n`)
+}
@@ -0,0 +1,298 @@
+package analyzers
+
+import (
+	"fmt"
+	"github.com/nuts-foundation/nuts-node/crypto/hash"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// ref returns the (fake) transaction reference of the node with the given name
+func ref(name string) hash.SHA256Hash {
+	return hash.SHA256Sum([]byte(name))
+}
+
+// testNode returns a node with the given name (see ref)
+func testNode(name string, did string, lc uint32, notes ...string) *Node {
+	return &Node{Transaction: ref(name), DID: did, LamportClock: lc, Notes: notes}
+}
+
+// testGraph returns a graph of the given nodes, connected by the given edges: pairs of node names, the previous
+// transaction first
+func testGraph(nodes []*Node, edges ...[2]string) *Graph {
+	graph := newGraph()
+	for _, node := range nodes {
+		graph.Nodes[node.Transaction] = node
+	}
+	for _, edge := range edges {
+		parent, child := ref(edge[0]), ref(edge[1])
+		if graph.Edges[parent] == nil {
+			graph.Edges[parent] = make(map[hash.SHA256Hash]bool)
+		}
+		graph.Edges[parent][child] = true
+	}
+	return graph
+}
+
+// names returns the names of the given nodes by their (fake) transaction reference, see ref
+func names(nodes ...string) map[hash.SHA256Hash]string {
+	result := make(map[hash.SHA256Hash]string)
+	for _, name := range nodes {
+		result[ref(name)] = name
+	}
+	return result
+}
+
+// assertGraph fails the test if the graph doesn't consist of exactly the given nodes and edges ("parent->child"),
+// which are named using the given names
+func assertGraph(t *testing.T, graph *Graph, nameOf map[hash.SHA256Hash]string, expectedNodes []string, expectedEdges []string) {
+	t.Helper()
+	var nodes, edges []string
+	for curr := range graph.Nodes {
+		nodes = append(nodes, nameOf[curr])
+	}
+	for parent, children := range graph.Edges {
+		for child := range children {
+			edges = append(edges, nameOf[parent]+"->"+nameOf[child])
+		}
+	}
+	for _, values := range [][]string{nodes, edges, expectedNodes, expectedEdges} {
+		sort.Strings(values)
+	}
+	if !reflect.DeepEqual(nodes, expectedNodes) {
+		t.Errorf("expected nodes [%s], got [%s]", strings.Join(expectedNodes, ", "), strings.Join(nodes, ", "))
+	}
+	if !reflect.DeepEqual(edges, expectedEdges) {
+		t.Errorf("expected edges [%s], got [%s]", strings.Join(expectedEdges, ", "), strings.Join(edges, ", "))
+	}
+}
+
+func TestGraph_pruneAfterDeactivation(t *testing.T) {
+	// A is created, updated and deactivated. An update of A after the deactivation is pruned, but the transactions of
+	// its controller B that refer to the deactivation are kept.
+	nameOf := names("create", "update", "deactivate", "after", "controller", "controller-update")
+	graph := testGraph([]*Node{
+		testNode("create", "did:nuts:A", 0, "created"),
+		testNode("update", "did:nuts:A", 1, "update"),
+		testNode("deactivate", "did:nuts:A", 2, "update", "deactivated"),
+		testNode("after", "did:nuts:A", 3, "update"),
+		testNode("controller", "did:nuts:B", 3, "update"),
+		testNode("controller-update", "did:nuts:B", 4, "update"),
+	},
+		[2]string{"create", "update"},
+		[2]string{"update", "deactivate"},
+		[2]string{"deactivate", "after"},
+		[2]string{"deactivate", "controller"},
+		[2]string{"controller", "controller-update"},
+	)
+
+	graph.pruneAfterDeactivation()
+
+	assertGraph(t, graph, nameOf,
+		[]string{"create", "update", "deactivate", "controller", "controller-update"},
+		[]string{"create->update", "update->deactivate", "deactivate->controller", "controller->controller-update"})
+	if !graph.Nodes[ref("deactivate")].Terminal {
+		t.Error("expected the deactivation to be terminal")
+	}
+	if graph.Nodes[ref("update")].Terminal {
+		t.Error("expected the update not to be terminal")
+	}
+}
+
+func TestGraph_markConflicts(t *testing.T) {
+	testCases := []struct {
+		name     string
+		nodes    []*Node
+		edges    [][2]string
+		expected []string
+	}{
+		{
+			name: "fork",
+			nodes: []*Node{
+				testNode("create", "did:nuts:A", 0, "created"),
+				testNode("left", "did:nuts:A", 1, "update"),
+				testNode("right", "did:nuts:A", 1, "update"),
+			},
+			edges:    [][2]string{{"create", "left"}, {"create", "right"}},
+			expected: []string{"left", "right"},
+		},
+		{
+			name: "fork with different lamport clocks",
+			nodes: []*Node{
+				testNode("create", "did:nuts:A", 0, "created"),
+				testNode("left", "did:nuts:A", 1, "update"),
+				testNode("right", "did:nuts:A", 5, "update"),
+			},
+			edges:    [][2]string{{"create", "left"}, {"create", "right"}},
+			expected: []string{"left", "right"},
+		},
+		{
+			name: "update building on its sibling",
+			nodes: []*Node{
+				testNode("create", "did:nuts:A", 0, "created"),
+				testNode("first", "did:nuts:A", 1, "update"),
+				testNode("second", "did:nuts:A", 2, "update"),
+			},
+			edges: [][2]string{{"create", "first"}, {"create", "second"}, {"first", "second"}},
+		},
+		{
+			name: "updates of different DIDs",
+			nodes: []*Node{
+				testNode("create", "did:nuts:A", 0, "created"),
+				testNode("update", "did:nuts:A", 1, "update"),
+				testNode("controller", "did:nuts:B", 1, "update"),
+			},
+			edges: [][2]string{{"create", "update"}, {"create", "controller"}},
+		},
+		{
+			name: "not updates",
+			nodes: []*Node{
+				testNode("root", "did:nuts:A", 0, "created"),
+				testNode("left", "did:nuts:B", 1, "created"),
+				testNode("right", "did:nuts:B", 1, "created"),
+			},
+			edges: [][2]string{{"root", "left"}, {"root", "right"}},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			graph := testGraph(testCase.nodes, testCase.edges...)
+
+			graph.markConflicts()
+
+			nodes := make(map[string]bool)
+			for _, edge := range testCase.edges {
+				nodes[edge[0]], nodes[edge[1]] = true, true
+			}
+			var actual []string
+			for name := range nodes {
+				node := graph.Nodes[ref(name)]
+				if node.Conflict != hasNote(node, "conflict") {
+					t.Errorf("conflict flag and note of %s disagree", name)
+				}
+				if node.Conflict {
+					actual = append(actual, name)
+				}
+			}
+			sort.Strings(actual)
+			if !reflect.DeepEqual(actual, testCase.expected) {
+				t.Errorf("expected conflicts %v, got %v", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestGraph_limitToWindow(t *testing.T) {
+	// A DID document that's updated at every lamport clock, 0 to 4
+	d := newTestDAG()
+	tx := d.add("0", didDocument("did:nuts:A", true), true)
+	for i := 1; i <= 4; i++ {
+		tx = d.add(fmt.Sprintf("%d", i), didDocument("did:nuts:A", true), false, tx)
+	}
+
+	graph := d.analyze(t, Options{MinLC: 2, MaxLC: 3}, "did:nuts:A")
+
+	// The versions just outside the window are kept as boundary, the others are left out
+	assertGraph(t, graph, d.names, []string{"1", "2", "3", "4"}, []string{"1->2", "2->3", "3->4"})
+	testCases := []struct {
+		name     string
+		boundary bool
+		notes    []string
+	}{
+		{"1", true, []string{"update", "older"}},
+		{"2", false, []string{"update"}},
+		{"3", false, []string{"update"}},
+		{"4", true, []string{"update", "newer"}},
+	}
+	for _, testCase := range testCases {
+		node := d.node(t, graph, testCase.name)
+		if node.Boundary != testCase.boundary {
+			t.Errorf("expected boundary of %s to be %v", testCase.name, testCase.boundary)
+		}
+		if !reflect.DeepEqual(node.Notes, testCase.notes) {
+			t.Errorf("expected notes of %s to be %v, got %v", testCase.name, testCase.notes, node.Notes)
+		}
+	}
+}
+
+func TestGraph_PlantUML(t *testing.T) {
+	root := testNode("root", "did:nuts:A", 0, "created")
+	root.Root = true
+	conflict := testNode("conflict", "did:nuts:A", 1, "update", `quoted "note"`)
+	conflict.Conflict = true
+	boundary := testNode("boundary", "did:nuts:B", 2, "update", "newer")
+	boundary.Boundary = true
+	graph := testGraph([]*Node{root, conflict, boundary}, [2]string{"root", "conflict"}, [2]string{"conflict", "boundary"})
+	rectangle := regexp.MustCompile(`^rectangle "[^"]*" as node_([0-9a-f]{64})( <<root>>)?( #[0-9A-Za-z.:;]+)?$`)
+	arrow := regexp.MustCompile(`^node_([0-9a-f]{64}) --> node_([0-9a-f]{64})$`)
+
+	for _, options := range []RenderOptions{{}, {ColorByDID: true, SigningKeys: true, NotePerLine: true}} {
+		lines := strings.Split(graph.PlantUML(options), "\n")
+
+		if lines[0] != "@startuml" {
+			t.Errorf("expected the first line to be @startuml, got %s", lines[0])
+		}
+		if lines[len(lines)-1] != "@enduml" {
+			t.Errorf("expected the last line to be @enduml, got %s", lines[len(lines)-1])
+		}
+		declared := make(map[string]bool)
+		var arrows [][]string
+		for _, line := range lines[1 : len(lines)-1] {
+			if match := rectangle.FindStringSubmatch(line); match != nil {
+				declared[match[1]] = true
+			} else if match := arrow.FindStringSubmatch(line); match != nil {
+				arrows = append(arrows, match[1:])
+			} else {
+				t.Errorf("invalid line: %s", line)
+			}
+		}
+		if len(declared) != len(graph.Nodes) {
+			t.Errorf("expected %d rectangles, got %d", len(graph.Nodes), len(declared))
+		}
+		if len(arrows) != graph.EdgeCount() {
+			t.Errorf("expected %d arrows, got %d", graph.EdgeCount(), len(arrows))
+		}
+		for _, nodes := range arrows {
+			if !declared[nodes[0]] || !declared[nodes[1]] {
+				t.Errorf("arrow between undeclared nodes: %s --> %s", nodes[0], nodes[1])
+			}
+		}
+	}
+}
+
+func TestDIDColor(t *testing.T) {
+	palette := []string{"red", "green", "blue"}
+	t.Run("deterministic", func(t *testing.T) {
+		for _, did := range []string{"did:nuts:A", "did:nuts:B", "did:nuts:C"} {
+			expected := DIDColor(did, palette)
+			for i := 0; i < 10; i++ {
+				if actual := DIDColor(did, palette); actual != expected {
+					t.Errorf("expected %s for %s, got %s", expected, did, actual)
+				}
+			}
+			if DIDColor(did, nil) != DIDColor(did, DefaultPalette) {
+				t.Errorf("expected the default palette to be used for %s", did)
+			}
+		}
+	})
+	t.Run("from the palette", func(t *testing.T) {
+		inPalette := make(map[string]bool)
+		for _, color := range palette {
+			inPalette[color] = true
+		}
+		used := make(map[string]bool)
+		for i := 0; i < 100; i++ {
+			color := DIDColor(fmt.Sprintf("did:nuts:%d", i), palette)
+			if !inPalette[color] {
+				t.Fatalf("expected a color from the palette, got %s", color)
+			}
+			used[color] = true
+		}
+		if len(used) != len(palette) {
+			t.Errorf("expected all colors of the palette to be used, got %d", len(used))
+		}
+	})
+}
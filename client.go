@@ -0,0 +1,458 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/nuts-foundation/nuts-node/crypto/hash"
+	networkAPI "github.com/nuts-foundation/nuts-node/network/api/v1"
+	"github.com/nuts-foundation/nuts-node/network/dag"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kinds of errors returned by the Client, which can be checked for using errors.Is
+var (
+	// ErrNotFound is returned when the node doesn't know the requested resource (e.g. an unknown transaction)
+	ErrNotFound = errors.New("not found")
+	// ErrTimeout is returned when the node didn't respond in time
+	ErrTimeout = errors.New("timeout")
+	// ErrBadResponse is returned when the node could not be reached or returned an unexpected response
+	ErrBadResponse = errors.New("bad response")
+	// ErrDecode is returned when the response of the node couldn't be decoded
+	ErrDecode = errors.New("decode error")
+)
+
+// clientError is an error returned by the Client. It has the message of its cause, but also matches its kind
+// (one of the errors above) when checked using errors.Is.
+type clientError struct {
+	kind  error
+	cause error
+}
+
+func (e clientError) Error() string {
+	return e.cause.Error()
+}
+
+func (e clientError) Is(target error) bool {
+	return target == e.kind
+}
+
+func (e clientError) Unwrap() error {
+	return e.cause
+}
+
+// Client reads transactions from the network API of a nuts node. Like the node itself, it treats a transaction
+// (the small, signed envelope) and its payload (which can be large) separately: both are cached on their own, so
+// reading the envelope (e.g. for its parents, lamport clock or content type) never fetches the payload.
+type Client struct {
+	// URL is the base URL of the nuts node, e.g. http://localhost:1323
+	URL        string
+	HTTPClient *http.Client
+
+	mutex        sync.Mutex
+	envelopes    map[hash.SHA256Hash]string
+	payloads     map[hash.SHA256Hash][]byte
+	lastResponse *ResponseInfo
+}
+
+// ResponseInfo describes a response of the node, for diagnosing connectivity problems
+type ResponseInfo struct {
+	// URL is the URL that was requested
+	URL string
+	// Status is the status line, e.g. 200 OK
+	Status string
+	Header http.Header
+	// Received is when the response was received
+	Received time.Time
+}
+
+// LastResponse returns the most recent response of the node, or nil if there's none yet
+func (c *Client) LastResponse() *ResponseInfo {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.lastResponse
+}
+
+// NewClient returns a Client for the nuts node at the given base URL
+func NewClient(url string) *Client {
+	return &Client{
+		URL:        url,
+		HTTPClient: newHTTPClient(),
+	}
+}
+
+// apiPrefix is the base path of the network API of the node, which differs between API versions
+var apiPrefix = defaultAPIPrefix
+
+// apiMountPath is the path the node is mounted at, e.g. behind a gateway, which all its APIs are requested below. It's
+// taken from -api-prefix and joined onto the node URL by parseNodeURL.
+var apiMountPath string
+
+// defaultAPIPrefix is the base path of the network API of the node if it isn't configured with -api-prefix
+const defaultAPIPrefix = "/internal/network/v1"
+
+// parseAPIPrefix validates the given base path of the network API and splits it into the path the node is mounted at
+// and the base path of the API on the node, both without trailing slash. The mount path is what precedes the default
+// base path, e.g. /gateway for /gateway/internal/network/v1. The prefix must be an absolute path, without query or
+// fragment.
+func parseAPIPrefix(prefix string) (mountPath string, apiPath string, err error) {
+	prefix = strings.TrimSuffix(strings.TrimSpace(prefix), "/")
+	parsed, err := url.Parse(prefix)
+	if err != nil || !strings.HasPrefix(prefix, "/") || parsed.Path != prefix {
+		return "", "", fmt.Errorf("invalid API prefix %q: expected an absolute path, e.g. /internal/network/v1", prefix)
+	}
+	if strings.HasSuffix(prefix, defaultAPIPrefix) {
+		return strings.TrimSuffix(prefix, defaultAPIPrefix), defaultAPIPrefix, nil
+	}
+	return "", prefix, nil
+}
+
+// parseNodeURL validates the given base URL of a nuts node and returns it without surrounding whitespace and trailing
+// slash, with apiMountPath joined onto it. It must be an absolute http(s) URL, since paths are appended to it as-is.
+func parseNodeURL(nodeURL string) (string, error) {
+	nodeURL = strings.TrimSuffix(strings.TrimSpace(nodeURL), "/")
+	parsed, err := url.Parse(nodeURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "", fmt.Errorf("invalid node URL %q: expected an absolute URL including scheme, e.g. http://localhost:1323", nodeURL)
+	}
+	if apiMountPath == "" {
+		return nodeURL, nil
+	}
+	return url.JoinPath(nodeURL, apiMountPath)
+}
+
+// newNetworkAPIClient returns the generated client of the network API of the node at the given URL, as used by the
+// analyzer. It always requests the default base path, so it can't talk to a different API configured with -api-prefix.
+func newNetworkAPIClient(nodeURL string, httpClient *http.Client) (*networkAPI.Client, error) {
+	if apiPrefix != defaultAPIPrefix {
+		return nil, fmt.Errorf("the analyzer only supports the network API at %s, not at -api-prefix %s", defaultAPIPrefix, apiPrefix)
+	}
+	return networkAPI.NewClient(nodeURL, networkAPI.WithHTTPClient(httpClient))
+}
+
+// TransactionsInRange returns the transactions where start <= lamport clock < end
+func (c *Client) TransactionsInRange(ctx context.Context, start int, end int) ([]string, error) {
+	body, err := c.get(ctx, fmt.Sprintf("%s/transaction?start=%d&end=%d", apiPrefix, start, end))
+	if err != nil {
+		return nil, err
+	}
+	var transactions []string
+	if err := json.Unmarshal(body, &transactions); err != nil {
+		return nil, clientError{kind: ErrDecode, cause: fmt.Errorf("failed to parse transactions: %w", err)}
+	}
+	// A body of null unmarshals without error, but means there are no transactions just like an empty list
+	if transactions == nil {
+		transactions = []string{}
+	}
+	for _, transaction := range transactions {
+		c.cacheEnvelope(hash.SHA256Sum([]byte(transaction)), transaction)
+	}
+	return transactions, nil
+}
+
+// maxBatchClocks is the maximum number of lamport clocks TransactionsAt fetches in a single request
+const maxBatchClocks = 100
+
+// fetchConcurrency is the maximum number of requests TransactionsAt issues concurrently. It's set by the bulk
+// subcommands using -concurrency; the viewer never fetches more than one request worth of lamport clocks at a time.
+var fetchConcurrency = 4
+
+// clockRange is a range of lamport clocks [start, end)
+type clockRange struct {
+	start int
+	end   int
+}
+
+// TransactionsAt returns the transactions at each of the given lamport clocks, keyed by lamport clock. Contiguous
+// lamport clocks are fetched using a single request, issuing up to fetchConcurrency requests at once. If such a
+// request returns a transaction that can't be parsed (so its lamport clock is unknown), the lamport clocks of that
+// request are fetched one by one instead.
+func (c *Client) TransactionsAt(ctx context.Context, clocks []int) (map[int][]string, error) {
+	sorted := append([]int(nil), clocks...)
+	sort.Ints(sorted)
+	var ranges []clockRange
+	for i := 0; i < len(sorted); {
+		// Find the run of contiguous lamport clocks starting at i
+		start := sorted[i]
+		end := start + 1
+		for i < len(sorted) && sorted[i] <= end && sorted[i] < start+maxBatchClocks {
+			if sorted[i] == end {
+				end++
+			}
+			i++
+		}
+		ranges = append(ranges, clockRange{start: start, end: end})
+	}
+
+	// Requests complete in any order, but since the result is keyed by lamport clock that doesn't matter. The first
+	// failing request cancels the others.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	result := make(map[int][]string, len(sorted))
+	errs := make([]error, len(ranges))
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, fetchConcurrency)
+	for i, r := range ranges {
+		semaphore <- struct{}{}
+		if ctx.Err() != nil {
+			<-semaphore
+			break
+		}
+		wg.Add(1)
+		go func(i int, r clockRange) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			batch, err := c.transactionsInClockRange(ctx, r)
+			if err != nil {
+				errs[i] = err
+				cancel()
+				return
+			}
+			mutex.Lock()
+			defer mutex.Unlock()
+			for clock, clockTransactions := range batch {
+				result[clock] = clockTransactions
+			}
+		}(i, r)
+	}
+	wg.Wait()
+	// Report the error of the lowest lamport clocks, rather than one caused by the cancellation
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+	}
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// transactionsInClockRange returns the transactions in the given range of lamport clocks, keyed by lamport clock (see
+// TransactionsAt)
+func (c *Client) transactionsInClockRange(ctx context.Context, r clockRange) (map[int][]string, error) {
+	transactions, err := c.TransactionsInRange(ctx, r.start, r.end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transactions at lamport clocks %d-%d: %w", r.start, r.end-1, err)
+	}
+	batch := make(map[int][]string, r.end-r.start)
+	for clock := r.start; clock < r.end; clock++ {
+		batch[clock] = []string{}
+	}
+	for _, transaction := range transactions {
+		tx, err := dag.ParseTransaction([]byte(transaction))
+		if err != nil {
+			batch = nil
+			break
+		}
+		batch[int(tx.Clock())] = append(batch[int(tx.Clock())], transaction)
+	}
+	if batch != nil {
+		return batch, nil
+	}
+	if r.end-r.start == 1 {
+		return map[int][]string{r.start: transactions}, nil
+	}
+	batch = make(map[int][]string, r.end-r.start)
+	for clock := r.start; clock < r.end; clock++ {
+		if batch[clock], err = c.TransactionsInRange(ctx, clock, clock+1); err != nil {
+			return nil, fmt.Errorf("failed to get transactions at lamport clock %d: %w", clock, err)
+		}
+	}
+	return batch, nil
+}
+
+// forEachClockBatch calls visit with the transactions of every lamport clock in [start, end), in order. They're
+// fetched in batches of enough lamport clocks at once to keep all concurrent requests busy. It stops at the first error.
+func (c *Client) forEachClockBatch(ctx context.Context, start int, end int, visit func(clock int, transactions []string)) error {
+	batchSize := maxBatchClocks * fetchConcurrency
+	for batchStart := start; batchStart < end; batchStart += batchSize {
+		var clocks []int
+		for clock := batchStart; clock < end && clock < batchStart+batchSize; clock++ {
+			clocks = append(clocks, clock)
+		}
+		transactions, err := c.TransactionsAt(ctx, clocks)
+		if err != nil {
+			return err
+		}
+		for _, clock := range clocks {
+			visit(clock, transactions[clock])
+		}
+	}
+	return nil
+}
+
+// Transaction returns the transaction (without payload) with the given reference, fetching it if it isn't cached yet
+func (c *Client) Transaction(ctx context.Context, ref hash.SHA256Hash) (string, error) {
+	c.mutex.Lock()
+	envelope, ok := c.envelopes[ref]
+	c.mutex.Unlock()
+	if ok {
+		return envelope, nil
+	}
+	body, err := c.get(ctx, fmt.Sprintf("%s/transaction/%s", apiPrefix, ref))
+	if err != nil {
+		return "", err
+	}
+	c.cacheEnvelope(ref, string(body))
+	return string(body), nil
+}
+
+// TransactionPayload returns the payload of the transaction with the given reference, fetching it if it isn't cached
+// yet
+func (c *Client) TransactionPayload(ctx context.Context, ref hash.SHA256Hash) ([]byte, error) {
+	c.mutex.Lock()
+	payload, ok := c.payloads[ref]
+	c.mutex.Unlock()
+	if ok {
+		return payload, nil
+	}
+	payload, err := c.get(ctx, fmt.Sprintf("%s/transaction/%s/payload", apiPrefix, ref))
+	if err != nil {
+		return nil, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.payloads == nil {
+		c.payloads = make(map[hash.SHA256Hash][]byte)
+	}
+	c.payloads[ref] = payload
+	return payload, nil
+}
+
+// cachedPayload returns the payload of the transaction with the given reference if it's cached, without fetching it
+func (c *Client) cachedPayload(ref hash.SHA256Hash) ([]byte, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	payload, ok := c.payloads[ref]
+	return payload, ok
+}
+
+// CacheSize returns the number of cached transactions and payloads, and their total size in bytes
+func (c *Client) CacheSize() (transactions int, payloads int, size int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, envelope := range c.envelopes {
+		size += len(envelope)
+	}
+	for _, payload := range c.payloads {
+		size += len(payload)
+	}
+	return len(c.envelopes), len(c.payloads), size
+}
+
+// ClearCache forgets all cached transactions and payloads, so they're fetched from the node again
+func (c *Client) ClearCache() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.envelopes = nil
+	c.payloads = nil
+}
+
+// cacheEnvelope caches the given transaction, so Transaction doesn't need to fetch it again
+func (c *Client) cacheEnvelope(ref hash.SHA256Hash, transaction string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.envelopes == nil {
+		c.envelopes = make(map[hash.SHA256Hash]string)
+	}
+	c.envelopes[ref] = transaction
+}
+
+// HeadInfo describes the head (end) of the node's DAG
+type HeadInfo struct {
+	// HighestClock is the highest lamport clock of the DAG
+	HighestClock int
+	// TransactionCount is the number of transactions in the DAG
+	TransactionCount int
+	// Heads are the transactions at the highest lamport clock, which are always heads of the DAG (nothing refers to
+	// them). The node doesn't tell about heads at lower lamport clocks (branches that weren't merged yet).
+	Heads []hash.SHA256Hash
+}
+
+// Head returns information about the head of the node's DAG, as reported by its diagnostics
+func (c *Client) Head(ctx context.Context) (HeadInfo, error) {
+	body, err := c.getAccepting(ctx, "/status/diagnostics", "application/json")
+	if err != nil {
+		return HeadInfo{}, err
+	}
+	var diagnostics struct {
+		Network struct {
+			State struct {
+				HighestClock     int `json:"dag_lc_high"`
+				TransactionCount int `json:"transaction_count"`
+			} `json:"state"`
+		} `json:"network"`
+	}
+	if err := json.Unmarshal(body, &diagnostics); err != nil {
+		return HeadInfo{}, clientError{kind: ErrDecode, cause: fmt.Errorf("failed to parse diagnostics: %w", err)}
+	}
+	result := HeadInfo{
+		HighestClock:     diagnostics.Network.State.HighestClock,
+		TransactionCount: diagnostics.Network.State.TransactionCount,
+	}
+	transactions, err := c.TransactionsInRange(ctx, result.HighestClock, result.HighestClock+1)
+	if err != nil {
+		return HeadInfo{}, err
+	}
+	for _, transaction := range transactions {
+		result.Heads = append(result.Heads, hash.SHA256Sum([]byte(transaction)))
+	}
+	return result, nil
+}
+
+// get performs a GET request on the given path of the node and returns the response body
+func (c *Client) get(ctx context.Context, path string) ([]byte, error) {
+	return c.getAccepting(ctx, path, "")
+}
+
+// getAccepting performs a GET request like get, asking for the given content type (if not empty)
+func (c *Client) getAccepting(ctx context.Context, path string, accept string) ([]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	if accept != "" {
+		request.Header.Set("Accept", accept)
+	}
+	response, err := c.HTTPClient.Do(request)
+	if err != nil {
+		return nil, clientError{kind: requestErrorKind(err), cause: fmt.Errorf("HTTP request failed: %w", err)}
+	}
+	defer response.Body.Close()
+	c.mutex.Lock()
+	c.lastResponse = &ResponseInfo{URL: request.URL.String(), Status: response.Status, Header: response.Header.Clone(), Received: time.Now()}
+	c.mutex.Unlock()
+	if response.StatusCode == http.StatusNotFound {
+		return nil, clientError{kind: ErrNotFound, cause: fmt.Errorf("unexpected status code: %d", response.StatusCode)}
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, clientError{kind: ErrBadResponse, cause: fmt.Errorf("unexpected status code: %d", response.StatusCode)}
+	}
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, clientError{kind: requestErrorKind(err), cause: fmt.Errorf("failed to read response body: %w", err)}
+	}
+	return body, nil
+}
+
+// requestErrorKind returns the kind of error for the given failed HTTP request: ErrTimeout if it took too long,
+// otherwise ErrBadResponse
+func requestErrorKind(err error) error {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return ErrTimeout
+	}
+	return ErrBadResponse
+}
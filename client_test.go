@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/nuts-foundation/nuts-node/crypto/hash"
+	"github.com/nuts-foundation/nuts-node/network/dag"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withAPIPrefix configures the given -api-prefix for the duration of the test
+func withAPIPrefix(t *testing.T, prefix string) {
+	originalMountPath, originalPrefix := apiMountPath, apiPrefix
+	t.Cleanup(func() {
+		apiMountPath, apiPrefix = originalMountPath, originalPrefix
+	})
+	var err error
+	if apiMountPath, apiPrefix, err = parseAPIPrefix(prefix); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAPIPrefix(t *testing.T) {
+	ref := hash.SHA256Sum([]byte("transaction"))
+	testCases := []struct {
+		name     string
+		prefix   string
+		expected string
+		analyzer bool
+	}{
+		{"default", defaultAPIPrefix, "/internal/network/v1/transaction/" + ref.String(), true},
+		{"mounted", "/gateway/internal/network/v1/", "/gateway/internal/network/v1/transaction/" + ref.String(), true},
+		{"other version", "/internal/network/v2", "/internal/network/v2/transaction/" + ref.String(), false},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			withAPIPrefix(t, testCase.prefix)
+			var requested []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requested = append(requested, r.URL.Path)
+				w.WriteHeader(http.StatusNotFound)
+			}))
+			defer server.Close()
+			nodeURL, err := parseNodeURL(server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, _ = NewClient(nodeURL).Transaction(context.Background(), ref)
+			networkClient, err := newNetworkAPIClient(nodeURL, http.DefaultClient)
+			if err == nil {
+				response, err := networkClient.GetTransaction(context.Background(), ref.String())
+				if err != nil {
+					t.Fatal(err)
+				}
+				_ = response.Body.Close()
+			}
+
+			if (err == nil) != testCase.analyzer {
+				t.Errorf("expected the analyzer to be supported: %v, got error %v", testCase.analyzer, err)
+			}
+			for _, path := range requested {
+				if path != testCase.expected {
+					t.Errorf("expected requests to %s, got %s", testCase.expected, path)
+				}
+			}
+			if len(requested) == 0 {
+				t.Error("expected requests to the node")
+			}
+		})
+	}
+}
+
+// fakeResponse is a response of a fakeNode
+type fakeResponse struct {
+	status int
+	body   string
+}
+
+// fakeNode starts a server that returns the given responses by request URI (path and query), and 404 for anything
+// else. It returns a Client for the server.
+func fakeNode(t *testing.T, responses map[string]fakeResponse) *Client {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response, ok := responses[r.URL.RequestURI()]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(response.status)
+		_, _ = w.Write([]byte(response.body))
+	}))
+	t.Cleanup(server.Close)
+	return NewClient(server.URL)
+}
+
+func TestClient_Head(t *testing.T) {
+	const diagnostics = `{"network":{"state":{"dag_lc_high":2,"transaction_count":5}}}`
+	const heads = "/internal/network/v1/transaction?start=2&end=3"
+	t.Run("ok", func(t *testing.T) {
+		transactions := []string{testTransaction(didDocumentHeader), testTransaction(credentialHeader)}
+		client := fakeNode(t, map[string]fakeResponse{
+			"/status/diagnostics": {http.StatusOK, diagnostics},
+			heads:                 {http.StatusOK, `["` + strings.Join(transactions, `","`) + `"]`},
+		})
+
+		head, err := client.Head(context.Background())
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expected := HeadInfo{
+			HighestClock:     2,
+			TransactionCount: 5,
+			Heads:            []hash.SHA256Hash{hash.SHA256Sum([]byte(transactions[0])), hash.SHA256Sum([]byte(transactions[1]))},
+		}
+		if !reflect.DeepEqual(head, expected) {
+			t.Errorf("expected %+v, got %+v", expected, head)
+		}
+	})
+	testCases := []struct {
+		name      string
+		responses map[string]fakeResponse
+		expected  error
+	}{
+		{"diagnostics not found", map[string]fakeResponse{}, ErrNotFound},
+		{"diagnostics failed", map[string]fakeResponse{"/status/diagnostics": {http.StatusInternalServerError, ""}}, ErrBadResponse},
+		{"invalid diagnostics", map[string]fakeResponse{"/status/diagnostics": {http.StatusOK, "garbage"}}, ErrDecode},
+		{"heads failed", map[string]fakeResponse{
+			"/status/diagnostics": {http.StatusOK, diagnostics},
+			heads:                 {http.StatusServiceUnavailable, ""},
+		}, ErrBadResponse},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := fakeNode(t, testCase.responses)
+
+			_, err := client.Head(context.Background())
+
+			if !errors.Is(err, testCase.expected) {
+				t.Errorf("expected %v, got %v", testCase.expected, err)
+			}
+		})
+	}
+}
+
+func TestClient_TransactionsInRange(t *testing.T) {
+	const path = "/internal/network/v1/transaction?start=0&end=1"
+	transaction := testTransaction(didDocumentHeader)
+	testCases := []struct {
+		name     string
+		body     string
+		expected []string
+		err      error
+	}{
+		{"transactions", `["` + transaction + `"]`, []string{transaction}, nil},
+		{"empty", `[]`, []string{}, nil},
+		{"null", `null`, []string{}, nil},
+		{"garbage", `garbage`, nil, ErrDecode},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := fakeNode(t, map[string]fakeResponse{path: {http.StatusOK, testCase.body}})
+
+			transactions, err := client.TransactionsInRange(context.Background(), 0, 1)
+
+			if testCase.err != nil {
+				if !errors.Is(err, testCase.err) {
+					t.Fatalf("expected %v, got %v", testCase.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			// An empty result is a non-nil slice, so it marshals to [] rather than null
+			if transactions == nil || !reflect.DeepEqual(transactions, testCase.expected) {
+				t.Errorf("expected %#v, got %#v", testCase.expected, transactions)
+			}
+		})
+	}
+}
+
+// withCachedPayload returns a signed DID document transaction, and has the client of the viewer return the given
+// payload for it (from its cache) for the duration of the test
+func withCachedPayload(t *testing.T, payload string) string {
+	tx := dag.CreateSignedTestTransaction(1, time.Now(), nil, didDocumentType, true)
+	originalClient := client
+	client = &Client{payloads: map[hash.SHA256Hash][]byte{tx.Ref(): []byte(payload)}}
+	payloadErrors = make(map[hash.SHA256Hash]error)
+	payloadVerifications = make(map[hash.SHA256Hash]string)
+	t.Cleanup(func() {
+		client = originalClient
+		payloadErrors = make(map[hash.SHA256Hash]error)
+		payloadVerifications = make(map[hash.SHA256Hash]string)
+	})
+	return string(tx.Data())
+}
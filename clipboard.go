@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/nuts-foundation/data-viewer/analyzers"
+	"github.com/nuts-foundation/nuts-node/crypto/hash"
+	"github.com/nuts-foundation/nuts-node/network/dag"
+	vdrAPI "github.com/nuts-foundation/nuts-node/vdr/api/v1"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// copyToClipboard copies the given text to the clipboard using an OSC52 escape sequence, which is supported by most
+// terminal emulators (also over SSH). The sequence is written to the controlling terminal (/dev/tty), so it reaches
+// the terminal even when stdout and stderr are redirected, or falls back to stderr if that's a terminal. In safe mode
+// nothing is copied and errSafeMode is returned.
+func copyToClipboard(text string) error {
+	if safeMode {
+		return errSafeMode
+	}
+	sequence := "\033]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\a"
+	if tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0); err == nil {
+		defer tty.Close()
+		if _, err := tty.WriteString(sequence); err != nil {
+			return fmt.Errorf("failed to write to the terminal: %w", err)
+		}
+		return nil
+	}
+	if !isTerminal(os.Stderr) {
+		return errors.New("no terminal to send the clipboard escape sequence to")
+	}
+	if _, err := os.Stderr.WriteString(sequence); err != nil {
+		return fmt.Errorf("failed to write to the terminal: %w", err)
+	}
+	return nil
+}
+
+// copyTransaction copies the current raw transaction to the clipboard, reporting the outcome in the status message.
+// If there's no transaction (e.g. it failed to load) nothing is copied.
+func copyTransaction() {
+	if dagSubIndex >= len(transactions[dagLamportClock]) {
+		statusMessage = "nothing to copy"
+	} else if err := copyToClipboard(transactions[dagLamportClock][dagSubIndex]); err != nil {
+		statusMessage = fmt.Sprintf("copy: %v", err)
+	} else {
+		statusMessage = "copied the raw transaction to the clipboard"
+	}
+}
+
+// copyHeader copies the decoded header of the current transaction to the clipboard, reporting the outcome in the
+// status message. If the header can't be decoded nothing is copied.
+func copyHeader() {
+	if dagSubIndex >= len(transactions[dagLamportClock]) {
+		statusMessage = "nothing to copy"
+	} else if header := decodeTransaction(transactions[dagLamportClock][dagSubIndex]); header.err != nil {
+		statusMessage = fmt.Sprintf("nothing copied: %v", header.err)
+	} else if err := copyToClipboard(header.indented); err != nil {
+		statusMessage = fmt.Sprintf("copy: %v", err)
+	} else {
+		statusMessage = "copied the decoded header to the clipboard"
+	}
+}
+
+// copyPosition copies the position (N.M) and hash of the current transaction to the clipboard, so it can be shared with
+// someone who can then start their viewer at it (using -start), reporting what was copied
+func copyPosition() {
+	if dagSubIndex >= len(transactions[dagLamportClock]) {
+		statusMessage = "nothing to copy"
+		return
+	}
+	reference := fmt.Sprintf("%d.%d %s", dagLamportClock, dagSubIndex, hash.SHA256Sum([]byte(transactions[dagLamportClock][dagSubIndex])))
+	if err := copyToClipboard(reference); err != nil {
+		statusMessage = fmt.Sprintf("copy: %v", err)
+	} else {
+		statusMessage = fmt.Sprintf("copied %s to the clipboard", reference)
+	}
+}
+
+// copyGraphCommand copies a shell command that renders the graph of the DID document in the current transaction to an
+// SVG file using the analyzer and Graphviz, reporting what was copied. The command analyzes the graph with the same
+// options as copyGraph and reaches the node the same way as the viewer, except for the API key (see httpFlags).
+func copyGraphCommand() {
+	_, document := currentDIDDocument()
+	if document == nil {
+		return
+	}
+	id := document.ID.String()
+	// The DID contains colons, which aren't allowed in file names everywhere
+	fileName := strings.Map(func(r rune) rune {
+		if r == '-' || r == '.' || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, id) + ".svg"
+	args := append(append(httpFlags(), graphOptionFlags(graphOptions())...), shellQuote(id))
+	command := fmt.Sprintf("NUTS_NODE_ADDRESS=%s data-viewer analyze did-graph %s | dot -Tsvg -o '%s'", nodeURL, strings.Join(args, " "), fileName)
+	if err := copyToClipboard(command); err != nil {
+		statusMessage = fmt.Sprintf("copy: %v", err)
+	} else {
+		statusMessage = fmt.Sprintf("copied the command rendering the graph of %s to %s to the clipboard", id, fileName)
+		if apiKeyParam != nil {
+			statusMessage += ", set API_KEY to the API key before running it"
+		}
+	}
+}
+
+// graphOptions returns the options the viewer analyzes graphs with: the defaults of the analyze did-graph subcommand
+func graphOptions() analyzers.Options {
+	return analyzers.Options{MaxNodes: defaultMaxNodes}
+}
+
+// graphOptionFlags returns the flags of the analyze did-graph subcommand that analyze a graph with the given options,
+// leaving out the ones that are at their default
+func graphOptionFlags(options analyzers.Options) []string {
+	var flags []string
+	if options.MinLC > 0 {
+		flags = append(flags, fmt.Sprintf("-min-lc %d", options.MinLC))
+	}
+	if options.MaxLC > 0 {
+		flags = append(flags, fmt.Sprintf("-max-lc %d", options.MaxLC))
+	}
+	if options.StopAtDeactivation {
+		flags = append(flags, "-stop-at-deactivation")
+	}
+	if options.TransactionTimeout > 0 {
+		flags = append(flags, "-timeout-per-tx "+options.TransactionTimeout.String())
+	}
+	if !options.AsOf.Empty() {
+		flags = append(flags, "-as-of "+options.AsOf.String())
+	}
+	if options.SkipControllers {
+		flags = append(flags, "-follow-controllers=false")
+	}
+	if options.IncludeUnreferenced {
+		flags = append(flags, "-include-unreferenced")
+	}
+	if options.FirstParent {
+		flags = append(flags, "-first-parent")
+	}
+	if options.MaxNodes != defaultMaxNodes {
+		flags = append(flags, fmt.Sprintf("-max-nodes %d", options.MaxNodes))
+	}
+	return flags
+}
+
+// graphAnalysisRunning is set while copyGraph analyzes a graph in the background
+var graphAnalysisRunning bool
+
+// copyGraph analyzes the graph of the DID document in the current transaction (like the command copied by
+// copyGraphCommand does) and copies it to the clipboard as Graphviz dot. The analysis runs in the background, reporting
+// its progress and outcome in the status message through backgroundUpdates.
+func copyGraph() {
+	if safeMode {
+		statusMessage = fmt.Sprintf("nothing copied: %v", errSafeMode)
+		return
+	}
+	if graphAnalysisRunning {
+		statusMessage = "already analyzing a graph, wait for it to finish"
+		return
+	}
+	_, document := currentDIDDocument()
+	if document == nil {
+		return
+	}
+	vdrClient, err := vdrAPI.NewClient(client.URL, vdrAPI.WithHTTPClient(client.HTTPClient))
+	if err != nil {
+		statusMessage = fmt.Sprintf("nothing copied: %v", err)
+		return
+	}
+	networkClient, err := newNetworkAPIClient(client.URL, client.HTTPClient)
+	if err != nil {
+		statusMessage = fmt.Sprintf("nothing copied: %v", err)
+		return
+	}
+	id := document.ID.String()
+	options := graphOptions()
+	options.Progress = func(nodes int, edges int) {
+		// Only the latest progress matters, so skip it if the event loop is behind
+		select {
+		case backgroundUpdates <- func() {
+			statusMessage = fmt.Sprintf("analyzing the graph of %s: discovered %d transactions, %d edges", id, nodes, edges)
+		}:
+		default:
+		}
+	}
+	graphAnalysisRunning = true
+	statusMessage = fmt.Sprintf("analyzing the graph of %s", id)
+	go func() {
+		graph, err := analyzers.NewDIDDocumentGraphAnalyzer(vdrClient, networkClient).Analyze(viewerContext, []string{id}, options)
+		backgroundUpdates <- func() {
+			graphAnalysisRunning = false
+			if err != nil {
+				statusMessage = fmt.Sprintf("nothing copied: failed to analyze the graph of %s: %v", id, err)
+			} else if err := copyToClipboard(graph.Dot(analyzers.RenderOptions{})); err != nil {
+				statusMessage = fmt.Sprintf("copy: %v", err)
+			} else {
+				statusMessage = fmt.Sprintf("copied the graph of %s (%d transactions) to the clipboard as dot", id, len(graph.Nodes))
+			}
+		}
+	}()
+}
+
+// copyPayload copies the payload of the current transaction to the clipboard, formatted for its content type (see
+// formatPayloadForCopy), reporting the outcome in the status message.
+func copyPayload() {
+	if dagSubIndex >= len(transactions[dagLamportClock]) {
+		statusMessage = "nothing to copy"
+		return
+	}
+	tx, err := dag.ParseTransaction([]byte(transactions[dagLamportClock][dagSubIndex]))
+	if err != nil {
+		statusMessage = fmt.Sprintf("nothing copied: failed to parse transaction: %v", err)
+		return
+	}
+	payload, err := loadPayload(tx.Ref())
+	if err != nil {
+		statusMessage = fmt.Sprintf("nothing copied: %v", err)
+	} else if err := copyToClipboard(formatPayloadForCopy(tx.PayloadType(), payload)); err != nil {
+		statusMessage = fmt.Sprintf("copy: %v", err)
+	} else {
+		statusMessage = fmt.Sprintf("copied the payload (%s) to the clipboard", tx.PayloadType())
+	}
+}
+
+// formatPayloadForCopy formats the given payload so it can be pasted as a document of its content type:
+//   - DID documents get their keys in the conventional order (see canonicalizeDIDDocument), indented with 4 spaces
+//   - verifiable credentials (JSON-LD) are indented with 2 spaces, as is customary for JSON-LD
+//   - other JSON is indented with 4 spaces, like in the viewer
+//
+// Payloads that aren't JSON are returned as-is.
+func formatPayloadForCopy(contentType string, payload []byte) string {
+	switch {
+	case !json.Valid(payload):
+		return string(payload)
+	case contentType == didDocumentType:
+		if canonical, err := canonicalizeDIDDocument(payload); err == nil {
+			payload = canonical
+		}
+	case contentType == "application/vc+json":
+		var indented bytes.Buffer
+		_ = json.Indent(&indented, payload, "", "  ")
+		return indented.String()
+	}
+	indented, _ := indentJSON(payload)
+	return indented
+}
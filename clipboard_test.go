@@ -0,0 +1,76 @@
+package main
+
+import (
+	"github.com/nuts-foundation/data-viewer/analyzers"
+	"github.com/nuts-foundation/nuts-node/crypto/hash"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGraphOptionFlags(t *testing.T) {
+	asOf := hash.SHA256Sum([]byte("version"))
+	testCases := []struct {
+		name     string
+		options  analyzers.Options
+		expected []string
+	}{
+		{"defaults", graphOptions(), nil},
+		{"window", analyzers.Options{MinLC: 2, MaxLC: 5, MaxNodes: defaultMaxNodes}, []string{"-min-lc 2", "-max-lc 5"}},
+		{"as of", analyzers.Options{AsOf: asOf, MaxNodes: defaultMaxNodes}, []string{"-as-of " + asOf.String()}},
+		{"traversal", analyzers.Options{SkipControllers: true, FirstParent: true, IncludeUnreferenced: true, StopAtDeactivation: true, MaxNodes: defaultMaxNodes},
+			[]string{"-stop-at-deactivation", "-follow-controllers=false", "-include-unreferenced", "-first-parent"}},
+		{"limits", analyzers.Options{TransactionTimeout: 5 * time.Second}, []string{"-timeout-per-tx 5s", "-max-nodes 0"}},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if actual := graphOptionFlags(testCase.options); !reflect.DeepEqual(actual, testCase.expected) {
+				t.Errorf("expected %v, got %v", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestHTTPFlags(t *testing.T) {
+	t.Cleanup(func() {
+		apiPrefix = defaultAPIPrefix
+		clientCertFile, clientKeyFile = "", ""
+		pinnedFingerprint = nil
+		apiKeyParam = nil
+	})
+	if flags := httpFlags(); flags != nil {
+		t.Errorf("expected no flags by default, got %v", flags)
+	}
+
+	apiPrefix = "/internal/network/v2"
+	clientCertFile, clientKeyFile = "/certs/client.pem", "/certs/it's.key"
+	pinnedFingerprint = []byte{0xab, 0x01}
+	apiKeyParam = url.Values{"key": []string{"secret"}}
+
+	expected := []string{"-api-prefix '/internal/network/v2'", "-client-cert '/certs/client.pem'",
+		`-client-key '/certs/it'\''s.key'`, "-pin ab01", `'-apikey-param=key='"$API_KEY"`}
+	actual := httpFlags()
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+	if strings.Contains(strings.Join(actual, " "), "secret") {
+		t.Error("expected the API key not to be included")
+	}
+}
+
+func TestCopyGraph_SafeMode(t *testing.T) {
+	safeMode = true
+	t.Cleanup(func() {
+		safeMode = false
+		statusMessage = ""
+	})
+
+	// Nothing is analyzed, which would fail without a transaction and client
+	copyGraph()
+
+	if !strings.Contains(statusMessage, errSafeMode.Error()) || graphAnalysisRunning {
+		t.Errorf("expected the graph not to be analyzed in safe mode, got %q", statusMessage)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"github.com/nuts-foundation/go-did/did"
+	"github.com/nuts-foundation/nuts-node/network/dag"
+)
+
+// describeControllers describes the controllers of the given DID document, numbered so they can be navigated to with
+// the number keys (see goToController)
+func describeControllers(document did.Document) []string {
+	if len(document.Controller) == 0 {
+		if len(document.VerificationMethod) == 0 {
+			return []string{"none (the document can't be updated anymore)"}
+		}
+		return []string{"none (controlled by its own keys)"}
+	}
+	var lines []string
+	for i, controller := range document.Controller {
+		line := fmt.Sprintf("%d: %s", i+1, controller)
+		if controller.Equals(document.ID) {
+			line += " (itself)"
+		}
+		lines = append(lines, line)
+	}
+	return append(lines, "press the number of a controller to go to its latest version")
+}
+
+// goToController moves to the latest version of the DID document of the given controller (counting from 1) of the DID
+// document in the current transaction, as resolved through the VDR.
+func goToController(number int) {
+	_, document := currentDIDDocument()
+	if document == nil {
+		return
+	}
+	if number < 1 || number > len(document.Controller) {
+		statusMessage = fmt.Sprintf("%s has no controller %d", document.ID, number)
+		return
+	}
+	controller := document.Controller[number-1].String()
+	resolved := resolveDID(controller)
+	if resolved.err != nil {
+		statusMessage = fmt.Sprintf("failed to resolve %s: %v", controller, resolved.err)
+		return
+	}
+	// A DID document can have multiple latest versions (when updated concurrently), pick the one with highest clock
+	var latest dag.Transaction
+	for _, ref := range resolved.result.DocumentMetadata.SourceTransactions {
+		rawTransaction, err := client.Transaction(viewerContext, ref)
+		if err != nil {
+			statusMessage = fmt.Sprintf("failed to get transaction %s: %v", ref, err)
+			return
+		}
+		tx, err := dag.ParseTransaction([]byte(rawTransaction))
+		if err != nil {
+			statusMessage = fmt.Sprintf("failed to parse transaction %s: %v", ref, err)
+			return
+		}
+		if latest == nil || tx.Clock() > latest.Clock() {
+			latest = tx
+		}
+	}
+	if latest == nil {
+		statusMessage = fmt.Sprintf("no transactions found for %s", controller)
+		return
+	}
+	goToTransaction(latest)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/nuts-foundation/nuts-node/crypto/hash"
+	"github.com/nuts-foundation/nuts-node/network/dag"
+	"sort"
+	"strings"
+)
+
+// didDocumentType is the content type of transactions containing a DID document
+const didDocumentType = "application/did+json"
+
+// canonicalDIDDocuments reorders the keys of DID documents into the conventional order (see didDocumentKeyOrder)
+var canonicalDIDDocuments bool = false
+
+// sortedKeys sorts the keys of all JSON objects (recursively) before display, making the output byte-stable for comparison
+var sortedKeys bool = false
+
+// didDocumentKeyOrder is the conventional order of the top-level keys of a DID document. Other keys follow alphabetically.
+var didDocumentKeyOrder = []string{"@context", "id", "controller", "alsoKnownAs", "verificationMethod", "authentication",
+	"assertionMethod", "keyAgreement", "capabilityInvocation", "capabilityDelegation", "service"}
+
+// summarizeTransaction describes the given transaction in a single line, for logs and lists: hash prefix, lamport clock,
+// content type and (for DID documents of which the payload was already fetched) the DID. It never fetches anything.
+func summarizeTransaction(client *Client, rawTransaction string) string {
+	tx, err := dag.ParseTransaction([]byte(rawTransaction))
+	if err != nil {
+		return fmt.Sprintf("%s (unparsable)", hash.SHA256Sum([]byte(rawTransaction)).String()[:12])
+	}
+	summary := fmt.Sprintf("%s LC=%d %s", tx.Ref().String()[:12], tx.Clock(), tx.PayloadType())
+	if tx.PayloadType() != didDocumentType {
+		return summary
+	}
+	if payload, ok := client.cachedPayload(tx.Ref()); ok {
+		var document struct {
+			ID string `json:"id"`
+		}
+		if json.Unmarshal(payload, &document) == nil && document.ID != "" {
+			summary += " " + document.ID
+		}
+	}
+	return summary
+}
+
+// decodeHeader decodes the protected header of the given raw transaction, which is a JWS in compact serialization
+// (header.payload.signature), and returns it as indented JSON.
+func decodeHeader(rawTransaction string) (string, error) {
+	rawJSON, err := decodeRawHeader(rawTransaction)
+	if err != nil {
+		return "", err
+	}
+	return indentJSON(maybeSortKeys(rawJSON))
+}
+
+// decodeRawHeader decodes the protected header of the given raw transaction and returns it byte-for-byte as stored
+func decodeRawHeader(rawTransaction string) ([]byte, error) {
+	// Split the transaction on dots (".") in which the first part is the base64 encoded JSON header
+	transactionParts := strings.Split(rawTransaction, ".")
+	// Decode the raw base64 data of the header. JWS segments are base64url encoded without padding (RFC 7515), so
+	// headers containing '-' or '_' fail to decode (and others decode incorrectly) using the standard alphabet.
+	rawJSON, err := base64.RawURLEncoding.DecodeString(transactionParts[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode header: %w", err)
+	}
+	return rawJSON, nil
+}
+
+// decodeSignature decodes the signature (third part) of the given raw transaction. It returns nil if the transaction
+// has no signature part or it's empty.
+func decodeSignature(rawTransaction string) ([]byte, error) {
+	transactionParts := strings.Split(rawTransaction, ".")
+	if len(transactionParts) < 3 || transactionParts[2] == "" {
+		return nil, nil
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(transactionParts[2])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode signature: %w", err)
+	}
+	return signature, nil
+}
+
+// jsonKind returns the kind of the top-level value of the given JSON: object, array, string, number, boolean or null.
+// It returns an empty string if the data isn't valid JSON.
+func jsonKind(data []byte) string {
+	if !json.Valid(data) {
+		return ""
+	}
+	trimmed := bytes.TrimSpace(data)
+	switch trimmed[0] {
+	case '{':
+		return "object"
+	case '[':
+		return "array"
+	case '"':
+		return "string"
+	case 't', 'f':
+		return "boolean"
+	case 'n':
+		return "null"
+	default:
+		return "number"
+	}
+}
+
+// describeJSONKind describes the given kind (see jsonKind) for the user, e.g. "JSON array" or "not JSON"
+func describeJSONKind(kind string) string {
+	if kind == "" {
+		return "not JSON"
+	}
+	return "JSON " + kind
+}
+
+// maybeSortKeys returns the given JSON with the keys of all objects sorted if sortedKeys is enabled. Otherwise, or if
+// it isn't valid JSON, it's returned as-is.
+func maybeSortKeys(data []byte) []byte {
+	if !sortedKeys {
+		return data
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	// Keep numbers as they are, instead of converting them to float64
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return data
+	}
+	// Maps are marshalled with sorted keys
+	sorted, err := json.Marshal(value)
+	if err != nil {
+		return data
+	}
+	return sorted
+}
+
+// indentJSON nicely formats and indents the given JSON
+func indentJSON(data []byte) (string, error) {
+	var prettyJSON bytes.Buffer
+	if err := json.Indent(&prettyJSON, data, "", "    "); err != nil {
+		return "", err
+	}
+	return prettyJSON.String(), nil
+}
+
+// canonicalizeDIDDocument reorders the top-level keys of the given DID document into the conventional order, so
+// successive versions are easier to compare. Nested values are left as-is. It fails if the document isn't a JSON object.
+func canonicalizeDIDDocument(document []byte) ([]byte, error) {
+	// null unmarshals into an empty map, which would turn it into {}
+	if kind := jsonKind(document); kind != "object" {
+		return nil, fmt.Errorf("not a JSON object: %s", describeJSONKind(kind))
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(document, &fields); err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, key := range didDocumentKeyOrder {
+		if _, ok := fields[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+	var others []string
+	for key := range fields {
+		if indexOf(didDocumentKeyOrder, key) == -1 {
+			others = append(others, key)
+		}
+	}
+	sort.Strings(others)
+	keys = append(keys, others...)
+
+	var result bytes.Buffer
+	result.WriteString("{")
+	for i, key := range keys {
+		if i > 0 {
+			result.WriteString(",")
+		}
+		name, _ := json.Marshal(key)
+		result.Write(name)
+		result.WriteString(":")
+		result.Write(fields[key])
+	}
+	result.WriteString("}")
+	return result.Bytes(), nil
+}
+
+// indexOf returns the index of the given value in the given slice, or -1 if it isn't in there
+func indexOf(values []string, value string) int {
+	for i, curr := range values {
+		if curr == value {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+const (
+	didDocumentHeader = `{"alg":"ES256","cty":"application/did+json","kid":"did:nuts:A#key-1","lc":1,"prevs":["a"],"ver":1}`
+	credentialHeader  = `{"alg":"ES256","cty":"application/vc+json","kid":"did:nuts:A#key-1","lc":2,"prevs":["b"],"ver":1}`
+)
+
+// encodeSegment encodes a JWS segment the way the node does (base64url without padding)
+func encodeSegment(data string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(data))
+}
+
+// testTransaction returns a three-segment JWS with the given header, and a fake payload hash and signature
+func testTransaction(header string) string {
+	return encodeSegment(header) + "." + encodeSegment("payload-hash") + "." + encodeSegment("signature")
+}
+
+func TestDecodeRawHeader(t *testing.T) {
+	testCases := []struct {
+		name        string
+		transaction string
+		expected    string
+		err         string
+	}{
+		{"DID document transaction", testTransaction(didDocumentHeader), didDocumentHeader, ""},
+		{"credential transaction", testTransaction(credentialHeader), credentialHeader, ""},
+		{"non-JSON header", testTransaction("not json"), "not json", ""},
+		{"invalid base64", "!!!." + encodeSegment("payload-hash") + ".sig", "", "failed to decode header: illegal base64 data at input byte 0"},
+		{"empty string", "", "", ""},
+		{"no dots", encodeSegment(didDocumentHeader), didDocumentHeader, ""},
+		// Encodes to a segment containing '_', which isn't in the standard base64 alphabet
+		{"base64url alphabet", testTransaction(`{"kid":"did:nuts:A#???"}`), `{"kid":"did:nuts:A#???"}`, ""},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual, err := decodeRawHeader(testCase.transaction)
+			if testCase.err != "" {
+				if err == nil || err.Error() != testCase.err {
+					t.Fatalf("expected error %q, got %v", testCase.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(actual) != testCase.expected {
+				t.Errorf("expected %q, got %q", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestDecodeTransaction(t *testing.T) {
+	testCases := []struct {
+		name        string
+		transaction string
+		expected    string
+		err         string
+	}{
+		{
+			name:        "DID document transaction",
+			transaction: testTransaction(didDocumentHeader),
+			expected: `{
+    "alg": "ES256",
+    "cty": "application/did+json",
+    "kid": "did:nuts:A#key-1",
+    "lc": 1,
+    "prevs": [
+        "a"
+    ],
+    "ver": 1
+}`,
+		},
+		{
+			name:        "credential transaction",
+			transaction: testTransaction(credentialHeader),
+			expected: `{
+    "alg": "ES256",
+    "cty": "application/vc+json",
+    "kid": "did:nuts:A#key-1",
+    "lc": 2,
+    "prevs": [
+        "b"
+    ],
+    "ver": 1
+}`,
+		},
+		{name: "non-JSON header", transaction: testTransaction("not json"), err: "invalid character 'o' in literal null (expecting 'u')"},
+		{name: "invalid base64", transaction: "!!!.x.y", err: "failed to decode header: illegal base64 data at input byte 0"},
+		{name: "empty string", transaction: "", err: "unexpected end of JSON input"},
+		{name: "no dots", transaction: encodeSegment(`{"lc":1}`), expected: "{\n    \"lc\": 1\n}"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual := decodeTransaction(testCase.transaction)
+			if testCase.err != "" {
+				if actual.err == nil || actual.err.Error() != testCase.err {
+					t.Fatalf("expected error %q, got %v", testCase.err, actual.err)
+				}
+				return
+			}
+			if actual.err != nil {
+				t.Fatalf("unexpected error: %v", actual.err)
+			}
+			if actual.indented != testCase.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", testCase.expected, actual.indented)
+			}
+			// Decoding again returns the cached result
+			if cached := decodeTransaction(testCase.transaction); cached.indented != actual.indented {
+				t.Errorf("expected cached result to equal the first result")
+			}
+		})
+	}
+}
+
+func TestIndentJSON(t *testing.T) {
+	testCases := []struct {
+		name     string
+		data     string
+		expected string
+		err      string
+	}{
+		{
+			name: "DID document",
+			data: `{"@context":"https://www.w3.org/ns/did/v1","id":"did:nuts:A","controller":["did:nuts:B"]}`,
+			expected: `{
+    "@context": "https://www.w3.org/ns/did/v1",
+    "id": "did:nuts:A",
+    "controller": [
+        "did:nuts:B"
+    ]
+}`,
+		},
+		{
+			name: "verifiable credential",
+			data: `{"type":["VerifiableCredential","NutsOrganizationCredential"],"issuer":"did:nuts:A","credentialSubject":{"id":"did:nuts:B"}}`,
+			expected: `{
+    "type": [
+        "VerifiableCredential",
+        "NutsOrganizationCredential"
+    ],
+    "issuer": "did:nuts:A",
+    "credentialSubject": {
+        "id": "did:nuts:B"
+    }
+}`,
+		},
+		{name: "non-JSON payload", data: "hello", err: "invalid character 'h' looking for beginning of value"},
+		{name: "empty", data: "", err: "unexpected end of JSON input"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual, err := indentJSON([]byte(testCase.data))
+			if testCase.err != "" {
+				if err == nil || err.Error() != testCase.err {
+					t.Fatalf("expected error %q, got %v", testCase.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if actual != testCase.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestDecodeSignature(t *testing.T) {
+	t.Run("three-segment JWS", func(t *testing.T) {
+		signature, err := decodeSignature(testTransaction(didDocumentHeader))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(signature) != "signature" {
+			t.Errorf("expected %q, got %q", "signature", signature)
+		}
+	})
+	t.Run("no dots", func(t *testing.T) {
+		signature, err := decodeSignature(encodeSegment(didDocumentHeader))
+		if err != nil || signature != nil {
+			t.Errorf("expected no signature and no error, got %q, %v", signature, err)
+		}
+	})
+	t.Run("invalid base64", func(t *testing.T) {
+		_, err := decodeSignature("a.b.!!!")
+		if err == nil || err.Error() != "failed to decode signature: illegal base64 data at input byte 0" {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+func TestJSONKind(t *testing.T) {
+	testCases := []struct {
+		data     string
+		expected string
+	}{
+		{`{"id":"did:nuts:A"}`, "object"},
+		{` {}`, "object"},
+		{`[{"id":"did:nuts:A"}]`, "array"},
+		{`"did:nuts:A"`, "string"},
+		{`42`, "number"},
+		{`-1.5e3`, "number"},
+		{`true`, "boolean"},
+		{`false`, "boolean"},
+		{`null`, "null"},
+		{"\n[]\n", "array"},
+		{``, ""},
+		{`{"id":`, ""},
+		{`hello`, ""},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.data, func(t *testing.T) {
+			if actual := jsonKind([]byte(testCase.data)); actual != testCase.expected {
+				t.Errorf("expected %q, got %q", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestCanonicalizeDIDDocument(t *testing.T) {
+	t.Run("object", func(t *testing.T) {
+		actual, err := canonicalizeDIDDocument([]byte(`{"service":[],"id":"did:nuts:A","@context":"https://www.w3.org/ns/did/v1"}`))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expected := `{"@context":"https://www.w3.org/ns/did/v1","id":"did:nuts:A","service":[]}`
+		if string(actual) != expected {
+			t.Errorf("expected %s, got %s", expected, actual)
+		}
+	})
+	testCases := []struct {
+		data string
+		err  string
+	}{
+		{`[{"id":"did:nuts:A"}]`, "not a JSON object: JSON array"},
+		{`"did:nuts:A"`, "not a JSON object: JSON string"},
+		{`null`, "not a JSON object: JSON null"},
+		{`hello`, "not a JSON object: not JSON"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.data, func(t *testing.T) {
+			_, err := canonicalizeDIDDocument([]byte(testCase.data))
+			if err == nil || err.Error() != testCase.err {
+				t.Errorf("expected error %q, got %v", testCase.err, err)
+			}
+		})
+	}
+}
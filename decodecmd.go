@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// runDecode implements the decode subcommand, which prints the decoded header of a transaction that doesn't need to be
+// on the node: it's given literally, read from stdin (no argument or -) or fetched from a URL (e.g. a gist or the
+// transaction endpoint of the node given with -node).
+func runDecode(args []string) {
+	flags := flag.NewFlagSet("decode", flag.ExitOnError)
+	node := flags.String("node", "", "base URL of the nuts node: only URLs on it are fetched using the HTTP flags (e.g. API key and TLS client certificate)")
+	registerHTTPFlags(flags)
+	flags.BoolVar(&sortedKeys, "sort-keys", false, "sort the keys of all JSON objects, for stable output")
+	_ = flags.Parse(args)
+	if flags.NArg() > 1 {
+		log.Fatal("decode takes a single transaction, URL or - (stdin) as argument")
+	}
+	nodeAddress := *node
+	if nodeAddress != "" {
+		var err error
+		if nodeAddress, err = setupNodeAccess(nodeAddress); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	rawTransaction, err := readTransactionInput(flags.Arg(0), nodeAddress)
+	if err != nil {
+		log.Fatal(err)
+	}
+	header, err := decodeHeader(rawTransaction)
+	if err != nil {
+		log.Fatalf("failed to decode transaction: %v", err)
+	}
+	fmt.Println(header)
+}
+
+// fetchTimeout is the maximum duration of fetching a transaction from a URL that's not on the node
+const fetchTimeout = 30 * time.Second
+
+// readTransactionInput returns the transaction given as argument to decode: read from stdin if empty or -, fetched if
+// it's an http(s) URL and as-is otherwise. A URL on the given node (if any) is fetched like all requests to the node,
+// any other URL with a plain HTTP client: the credentials of the node (API key and TLS client certificate) must not be
+// sent to other hosts, and the pinned certificate (-pin) of the node wouldn't match theirs.
+func readTransactionInput(input string, nodeURL string) (string, error) {
+	if input == "" || input == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", fmt.Errorf("failed to read stdin: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	if !strings.HasPrefix(input, "http://") && !strings.HasPrefix(input, "https://") {
+		return strings.TrimSpace(input), nil
+	}
+	// Validate the URL like a node URL, but keep it as-is since it's fetched verbatim
+	if _, err := parseNodeURL(input); err != nil {
+		return "", fmt.Errorf("invalid URL %q", input)
+	}
+	client := &Client{URL: input, HTTPClient: &http.Client{Timeout: fetchTimeout}}
+	if isOnNode(input, nodeURL) {
+		client = NewClient(input)
+	}
+	data, err := client.get(context.Background(), "")
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch transaction from %s: %w", input, err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// isOnNode returns whether the given URL is on the node with the given base URL (if any): it has the same scheme and
+// host, and its path starts with the path of the node
+func isOnNode(rawURL string, nodeURL string) bool {
+	if nodeURL == "" {
+		return false
+	}
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	node, err := url.Parse(nodeURL)
+	if err != nil {
+		return false
+	}
+	return target.Scheme == node.Scheme && strings.EqualFold(target.Host, node.Host) &&
+		(node.Path == "" || target.Path == node.Path || strings.HasPrefix(target.Path, node.Path+"/"))
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestReadTransactionInput_URL(t *testing.T) {
+	original := apiKeyParam
+	apiKeyParam = url.Values{"apikey": []string{"secret"}}
+	sharedTransport = nil
+	t.Cleanup(func() {
+		apiKeyParam = original
+		sharedTransport = nil
+	})
+	// Both servers return the query they were requested with, in place of a transaction
+	echoQuery := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.URL.RawQuery))
+	})
+	node := httptest.NewServer(echoQuery)
+	defer node.Close()
+	other := httptest.NewServer(echoQuery)
+	defer other.Close()
+	testCases := []struct {
+		name     string
+		input    string
+		nodeURL  string
+		expected string
+	}{
+		{"on the node", node.URL + "/internal/network/v1/transaction/abc", node.URL, "apikey=secret"},
+		{"other host", other.URL + "/transaction", node.URL, ""},
+		{"no node", node.URL + "/internal/network/v1/transaction/abc", "", ""},
+		{"outside the path of the node", node.URL + "/other", node.URL + "/gateway", ""},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual, err := readTransactionInput(testCase.input, testCase.nodeURL)
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if actual != testCase.expected {
+				t.Errorf("expected query %q, got %q", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestIsOnNode(t *testing.T) {
+	testCases := []struct {
+		url      string
+		nodeURL  string
+		expected bool
+	}{
+		{"https://node.example.com/internal/network/v1/transaction/abc", "https://node.example.com", true},
+		{"https://NODE.example.com/x", "https://node.example.com", true},
+		{"http://node.example.com/x", "https://node.example.com", false},
+		{"https://node.example.com:8443/x", "https://node.example.com", false},
+		{"https://gist.example.com/x", "https://node.example.com", false},
+		{"https://node.example.com/gateway/x", "https://node.example.com/gateway", true},
+		{"https://node.example.com/gateway", "https://node.example.com/gateway", true},
+		{"https://node.example.com/gateway2/x", "https://node.example.com/gateway", false},
+		{"https://node.example.com/x", "", false},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.url, func(t *testing.T) {
+			if actual := isOnNode(testCase.url, testCase.nodeURL); actual != testCase.expected {
+				t.Errorf("expected %v for node %q, got %v", testCase.expected, testCase.nodeURL, actual)
+			}
+		})
+	}
+}
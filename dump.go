@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// dumpDir is the directory the raw response bodies of the node are written to for debugging, dumping is disabled if empty
+var dumpDir string
+
+// unsafeFileNameCharacters matches the characters that are replaced when turning a request URI into a file name
+var unsafeFileNameCharacters = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// dumpTransport is an http.RoundTripper that writes each response body to a timestamped file in dir
+type dumpTransport struct {
+	dir  string
+	next http.RoundTripper
+}
+
+func (t dumpTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	response, err := t.next.RoundTrip(request)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(response.Body)
+	_ = response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	response.Body = io.NopCloser(bytes.NewReader(body))
+	name := fmt.Sprintf("%s%s.body", time.Now().Format("20060102T150405.000000000"),
+		unsafeFileNameCharacters.ReplaceAllString(request.URL.RequestURI(), "_"))
+	// Failing to dump must not fail the request, the dumps are merely a debugging aid
+	_ = os.WriteFile(filepath.Join(t.dir, name), body, 0644)
+	return response, nil
+}
+
+// prepareDumpDir creates dumpDir if dumping is enabled. Dumping writes files, so it's refused in safe mode.
+func prepareDumpDir() error {
+	if dumpDir == "" {
+		return nil
+	}
+	if safeMode {
+		return fmt.Errorf("-dump-dir: %w", errSafeMode)
+	}
+	if err := os.MkdirAll(dumpDir, 0755); err != nil {
+		return fmt.Errorf("failed to create dump directory: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// exportDir is the directory the x key exports the current view to
+var exportDir = "."
+
+// exportANSI keeps the colors of the exported view as ANSI escape codes, instead of exporting plain text
+var exportANSI bool = false
+
+// shownTitle, shownText and shownInfoLine are what the main view currently shows, as captured while rendering
+var shownTitle, shownText, shownInfoLine string
+
+// styleMarkup matches the termui style markup, e.g. [text](fg:red,mod:bold)
+var styleMarkup = regexp.MustCompile(`\[([^\]]*)\]\(([^)]*)\)`)
+
+// ansiCodes maps termui style items to ANSI SGR codes
+var ansiCodes = map[string]string{
+	"fg:black": "30", "fg:red": "31", "fg:green": "32", "fg:yellow": "33",
+	"fg:blue": "34", "fg:magenta": "35", "fg:cyan": "36", "fg:white": "37",
+	"mod:bold": "1", "mod:underline": "4",
+}
+
+// exportView writes what the main view currently shows (title, text and info line) to a file in exportDir, without
+// borders or other chrome, and returns the path of the file
+func exportView() (string, error) {
+	if safeMode {
+		return "", errSafeMode
+	}
+	extension := "txt"
+	if exportANSI {
+		extension = "ans"
+	}
+	path := filepath.Join(exportDir, fmt.Sprintf("transaction-%d.%d.%s", dagLamportClock, dagSubIndex, extension))
+	content := strings.Trim(shownTitle, "| ") + "\n\n" + shownText + "\n\n" + shownInfoLine + "\n"
+	if err := os.WriteFile(path, []byte(convertStyleMarkup(content, exportANSI)), 0644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// convertStyleMarkup replaces the termui style markup in the given text by ANSI escape codes, or removes it
+func convertStyleMarkup(text string, ansi bool) string {
+	return styleMarkup.ReplaceAllStringFunc(text, func(markup string) string {
+		parts := styleMarkup.FindStringSubmatch(markup)
+		if !ansi {
+			return parts[1]
+		}
+		var codes []string
+		for _, item := range strings.Split(parts[2], ",") {
+			if code, ok := ansiCodes[strings.TrimSpace(item)]; ok {
+				codes = append(codes, code)
+			}
+		}
+		if len(codes) == 0 {
+			return parts[1]
+		}
+		return "\x1b[" + strings.Join(codes, ";") + "m" + parts[1] + "\x1b[0m"
+	})
+}
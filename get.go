@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/nuts-foundation/nuts-node/crypto/hash"
+	"github.com/nuts-foundation/nuts-node/network/dag"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// runGet implements the get subcommand, which prints a single transaction identified by its position (N or N.M)
+// or its hash to stdout, without starting the interactive viewer.
+func runGet(args []string) {
+	flags := flag.NewFlagSet("get", flag.ExitOnError)
+	flags.StringVar(&nodeURL, "node", nodeURL, "base URL of the nuts node")
+	registerHTTPFlags(flags)
+	format := flags.String("format", "payload", "output format: raw, payload, header or all")
+	flags.BoolVar(&rawPayload, "raw-payload", false, "print the payload verbatim instead of as indented JSON")
+	flags.BoolVar(&sortedKeys, "sort-keys", false, "sort the keys of all JSON objects, for stable output")
+	flags.BoolVar(&canonicalDIDDocuments, "canonical", false, "print DID documents with their keys in the conventional order")
+	flags.StringVar(&transactionOrder, "sort", "node", "order of the transactions within a lamport clock: node, hash or time")
+	_ = flags.Parse(args)
+	if !validTransactionOrder(transactionOrder) {
+		log.Fatalf("invalid sort order: %s", transactionOrder)
+	}
+	if flags.NArg() != 1 {
+		log.Fatal("get requires a transaction position (N.M) or hash as argument")
+	}
+	var err error
+	if nodeURL, err = setupNodeAccess(nodeURL); err != nil {
+		log.Fatal(err)
+	}
+
+	ctx := context.Background()
+	client := NewClient(nodeURL)
+	rawTransaction, err := getTransaction(ctx, client, flags.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	output, err := formatTransaction(ctx, client, rawTransaction, *format)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(output)
+}
+
+// getTransaction fetches the transaction identified by the given position (N or N.M) or hash
+func getTransaction(ctx context.Context, client *Client, positionOrHash string) (string, error) {
+	if ref, err := hash.ParseHex(positionOrHash); err == nil {
+		rawTransaction, err := client.Transaction(ctx, ref)
+		if err != nil {
+			return "", fmt.Errorf("failed to get transaction %s: %w", ref, err)
+		}
+		return rawTransaction, nil
+	}
+	clock, subIndex, err := parsePosition(positionOrHash)
+	if err != nil {
+		return "", err
+	}
+	transactions, err := client.TransactionsInRange(ctx, clock, clock+1)
+	if err != nil {
+		return "", fmt.Errorf("failed to get transactions at lamport clock %d: %w", clock, err)
+	}
+	sortTransactions(transactions, transactionOrder)
+	if subIndex >= len(transactions) {
+		return "", fmt.Errorf("no transaction at position %d.%d (lamport clock %d has %d transactions)", clock, subIndex, clock, len(transactions))
+	}
+	return transactions[subIndex], nil
+}
+
+// parsePosition parses a transaction position in the form of N (lamport clock) or N.M (lamport clock and sub index)
+func parsePosition(position string) (int, int, error) {
+	clockPart, subIndexPart, hasSubIndex := strings.Cut(position, ".")
+	clock, err := strconv.ParseUint(clockPart, 10, 31)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid transaction position %q: %w", position, err)
+	}
+	if !hasSubIndex {
+		return int(clock), 0, nil
+	}
+	subIndex, err := strconv.ParseUint(subIndexPart, 10, 31)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid transaction position %q: %w", position, err)
+	}
+	return int(clock), int(subIndex), nil
+}
+
+// formatTransaction renders the given raw transaction in the given format:
+//   - raw: the transaction as JWS
+//   - header: the decoded JWS header
+//   - payload: the transaction payload, fetched from the node
+//   - all: a JSON object containing all of the above
+func formatTransaction(ctx context.Context, client *Client, rawTransaction string, format string) (string, error) {
+	switch format {
+	case "raw":
+		return rawTransaction, nil
+	case "header":
+		return decodeHeader(rawTransaction)
+	case "payload":
+		tx, payload, err := fetchPayload(ctx, client, rawTransaction)
+		if err != nil {
+			return "", err
+		}
+		if rawPayload {
+			return string(payload), nil
+		}
+		payload = maybeSortKeys(payload)
+		if canonicalDIDDocuments && tx.PayloadType() == didDocumentType {
+			if canonical, err := canonicalizeDIDDocument(payload); err == nil {
+				payload = canonical
+			}
+		}
+		if formatted, err := indentJSON(payload); err == nil {
+			return formatted, nil
+		}
+		return string(payload), nil
+	case "all":
+		header, err := decodeHeader(rawTransaction)
+		if err != nil {
+			return "", err
+		}
+		_, payload, err := fetchPayload(ctx, client, rawTransaction)
+		if err != nil {
+			return "", err
+		}
+		payload = maybeSortKeys(payload)
+		all := map[string]interface{}{
+			"raw":    rawTransaction,
+			"header": json.RawMessage(header),
+		}
+		if json.Valid(payload) {
+			all["payload"] = json.RawMessage(payload)
+		} else {
+			all["payload"] = string(payload)
+		}
+		data, err := json.MarshalIndent(all, "", "    ")
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	default:
+		return "", fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+// fetchPayload parses the given raw transaction and fetches its payload from the node
+func fetchPayload(ctx context.Context, client *Client, rawTransaction string) (dag.Transaction, []byte, error) {
+	tx, err := dag.ParseTransaction([]byte(rawTransaction))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse transaction: %w", err)
+	}
+	payload, err := client.TransactionPayload(ctx, tx.Ref())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get payload of transaction %s: %w", tx.Ref(), err)
+	}
+	return tx, payload, nil
+}
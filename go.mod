@@ -4,6 +4,7 @@ go 1.19
 
 require (
 	github.com/gizak/termui/v3 v3.1.0
+	github.com/lestrrat-go/jwx v1.2.25
 	github.com/nuts-foundation/go-did v0.4.0
 	github.com/nuts-foundation/nuts-node v1.0.1-0.20230227155229-c9db91212517
 )
@@ -46,7 +47,6 @@ require (
 	github.com/lestrrat-go/blackmagic v1.0.1 // indirect
 	github.com/lestrrat-go/httpcc v1.0.1 // indirect
 	github.com/lestrrat-go/iter v1.0.2 // indirect
-	github.com/lestrrat-go/jwx v1.2.25 // indirect
 	github.com/lestrrat-go/option v1.0.0 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.17 // indirect
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"github.com/nuts-foundation/nuts-node/crypto/hash"
+	"github.com/nuts-foundation/nuts-node/network/dag"
+)
+
+// heads caches whether transactions are a head of the DAG (nothing refers to them), keyed by transaction reference.
+// It's derived from the head of the DAG as last queried (see refreshHead), so it's cleared whenever that's refreshed.
+var heads map[hash.SHA256Hash]bool
+
+// headTransactions are the transactions at headClock, as last queried (see refreshHead)
+var headTransactions []hash.SHA256Hash
+
+// headQueryFailed is set when querying the head of the DAG failed, so that isn't attempted again on every render until
+// the user retries
+var headQueryFailed bool
+
+// refreshHead queries the head of the DAG, updating headClock and headTransactions, and forgets the head status of
+// transactions derived from the previous head
+func refreshHead() (HeadInfo, error) {
+	head, err := client.Head(viewerContext)
+	if err != nil {
+		headQueryFailed = true
+		return HeadInfo{}, err
+	}
+	headQueryFailed = false
+	headClock, headTransactions = head.HighestClock, head.Heads
+	heads = make(map[hash.SHA256Hash]bool)
+	return head, nil
+}
+
+// isHead returns whether the given transaction is one of the transactions at the highest lamport clock of the DAG,
+// which nothing refers to. It uses the head as last queried, which is only queried here if it isn't known yet: it's
+// refreshed when the user retries (r) or moves beyond it, so browsing doesn't query the node. Heads of branches at
+// lower lamport clocks that weren't merged yet aren't detected, since the node doesn't tell about those.
+func isHead(tx dag.Transaction) bool {
+	if head, ok := heads[tx.Ref()]; ok {
+		return head
+	}
+	if headClock < 0 {
+		if headQueryFailed {
+			return false
+		}
+		if _, err := refreshHead(); err != nil {
+			return false
+		}
+	}
+	head := false
+	if int(tx.Clock()) == headClock {
+		for _, ref := range headTransactions {
+			if ref.Equals(tx.Ref()) {
+				head = true
+			}
+		}
+	}
+	heads[tx.Ref()] = head
+	return head
+}
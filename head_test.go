@@ -0,0 +1,54 @@
+package main
+
+import (
+	"github.com/nuts-foundation/nuts-node/network/dag"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestIsHead(t *testing.T) {
+	// The DAG consists of a root and the transaction referring to it, which is the head
+	root := dag.CreateSignedTestTransaction(1, time.Now(), nil, didDocumentType, true)
+	head := dag.CreateSignedTestTransaction(2, time.Now(), nil, didDocumentType, false, root)
+	queries := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.RequestURI() {
+		case "/status/diagnostics":
+			queries++
+			_, _ = w.Write([]byte(`{"network":{"state":{"dag_lc_high":1,"transaction_count":2}}}`))
+		case "/internal/network/v1/transaction?start=1&end=2":
+			_, _ = w.Write([]byte(`["` + string(head.Data()) + `"]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	originalClient := client
+	client = NewClient(server.URL)
+	t.Cleanup(func() {
+		clearCaches()
+		client = originalClient
+	})
+	clearCaches()
+
+	for i := 0; i < 3; i++ {
+		if !isHead(head) {
+			t.Error("expected the transaction at the highest lamport clock to be a head")
+		}
+		if isHead(root) {
+			t.Error("expected the root not to be a head")
+		}
+	}
+	if queries != 1 {
+		t.Errorf("expected the head to be queried once, got %d", queries)
+	}
+
+	retryFailedFetch()
+	isHead(head)
+
+	if queries != 2 {
+		t.Errorf("expected retrying to query the head again, got %d queries", queries)
+	}
+}
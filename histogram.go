@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+)
+
+// runHistogram implements the histogram subcommand, which prints the number of transactions at each lamport clock in
+// the range [start, end) as clock,count lines, or as a bar chart for humans.
+func runHistogram(args []string) {
+	flags := flag.NewFlagSet("histogram", flag.ExitOnError)
+	flags.StringVar(&nodeURL, "node", nodeURL, "base URL of the nuts node")
+	registerHTTPFlags(flags)
+	registerConcurrencyFlag(flags)
+	start := flags.Int("start", 0, "first lamport clock (inclusive)")
+	end := flags.Int("end", -1, "last lamport clock (exclusive), defaults to the end of the DAG")
+	bars := flags.Bool("bars", false, "print a bar chart instead of clock,count lines")
+	_ = flags.Parse(args)
+	var err error
+	if nodeURL, err = setupNodeAccess(nodeURL); err != nil {
+		log.Fatal(err)
+	}
+
+	// Allow interrupting long ranges
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	client := NewClient(nodeURL)
+	if *end < 0 {
+		head, err := client.Head(ctx)
+		if err != nil {
+			log.Fatalf("failed to determine the end of the DAG (use -end): %v", err)
+		}
+		*end = head.HighestClock + 1
+	}
+	err = client.forEachClockBatch(ctx, *start, *end, func(clock int, transactions []string) {
+		if *bars {
+			fmt.Printf("%6d %4d %s\n", clock, len(transactions), strings.Repeat("#", len(transactions)))
+		} else {
+			fmt.Printf("%d,%d\n", clock, len(transactions))
+		}
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/nuts-foundation/data-viewer/analyzers"
+	"html/template"
+	"os/exec"
+	"strings"
+)
+
+// isHTMLFile returns whether the output of the analyzer is written to an HTML file, which gets a page showing the
+// graph instead of the graph in the requested format
+func isHTMLFile(file string) bool {
+	return strings.HasSuffix(strings.ToLower(file), ".html")
+}
+
+// renderGraphPage renders the given graph as a standalone HTML page with the given title. The graph is rendered to SVG
+// using Graphviz (dot) and can be panned and zoomed. If Graphviz isn't installed, or in safe mode (which doesn't run
+// other programs), the page renders the graph from its Mermaid source instead, which does require the browser to load
+// Mermaid.
+func renderGraphPage(graph *analyzers.Graph, title string, options analyzers.RenderOptions) (string, error) {
+	page := graphPage{
+		Title: title,
+		Nodes: len(graph.Nodes),
+		Edges: graph.EdgeCount(),
+	}
+	if _, err := exec.LookPath("dot"); err == nil && !safeMode {
+		svg, err := renderSVG(graph.Dot(options))
+		if err != nil {
+			return "", err
+		}
+		page.SVG = template.HTML(svg)
+	} else {
+		page.Mermaid = graph.Mermaid(options)
+	}
+	var result bytes.Buffer
+	if err := graphPageTemplate.Execute(&result, page); err != nil {
+		return "", fmt.Errorf("failed to render HTML page: %w", err)
+	}
+	return result.String(), nil
+}
+
+// renderSVG renders the given dot source to SVG using Graphviz, leaving out the XML declaration and doctype so it can
+// be embedded in HTML
+func renderSVG(dot string) (string, error) {
+	cmd := exec.Command("dot", "-Tsvg")
+	cmd.Stdin = strings.NewReader(dot)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to render SVG using Graphviz: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	svg := string(output)
+	if start := strings.Index(svg, "<svg"); start >= 0 {
+		svg = svg[start:]
+	}
+	return svg, nil
+}
+
+// graphPage is what the graph page shows: either the SVG or the Mermaid source of the graph
+type graphPage struct {
+	Title   string
+	Nodes   int
+	Edges   int
+	SVG     template.HTML
+	Mermaid string
+}
+
+var graphPageTemplate = template.Must(template.New("graph").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { margin: 0; font-family: sans-serif; }
+header { padding: 0.5em 1em; border-bottom: 1px solid #ccc; }
+#viewport { overflow: hidden; height: calc(100vh - 3em); cursor: grab; }
+#graph { transform-origin: 0 0; }
+</style>
+</head>
+<body>
+<header><strong>{{.Title}}</strong>: {{.Nodes}} transactions, {{.Edges}} edges{{if .SVG}} (scroll to zoom, drag to pan){{end}}</header>
+{{if .SVG}}<div id="viewport"><div id="graph">{{.SVG}}</div></div>
+<script>
+(function () {
+    var viewport = document.getElementById("viewport"), graph = document.getElementById("graph");
+    var scale = 1, x = 0, y = 0, drag = null;
+    function apply() {
+        graph.style.transform = "translate(" + x + "px," + y + "px) scale(" + scale + ")";
+    }
+    viewport.addEventListener("wheel", function (e) {
+        e.preventDefault();
+        // Zoom around the mouse pointer
+        var factor = e.deltaY < 0 ? 1.1 : 1 / 1.1, rect = viewport.getBoundingClientRect();
+        var px = e.clientX - rect.left, py = e.clientY - rect.top;
+        x = px - (px - x) * factor;
+        y = py - (py - y) * factor;
+        scale *= factor;
+        apply();
+    }, {passive: false});
+    viewport.addEventListener("mousedown", function (e) {
+        drag = {x: e.clientX - x, y: e.clientY - y};
+    });
+    window.addEventListener("mousemove", function (e) {
+        if (drag) {
+            x = e.clientX - drag.x;
+            y = e.clientY - drag.y;
+            apply();
+        }
+    });
+    window.addEventListener("mouseup", function () {
+        drag = null;
+    });
+})();
+</script>
+{{else}}<pre class="mermaid">
+{{.Mermaid}}
+</pre>
+<script type="module">
+import mermaid from "https://cdn.jsdelivr.net/npm/mermaid@10/dist/mermaid.esm.min.mjs";
+mermaid.initialize({startOnLoad: true, maxTextSize: 1000000});
+</script>
+{{end}}</body>
+</html>`))
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	ui "github.com/gizak/termui/v3"
+	"github.com/gizak/termui/v3/widgets"
+)
+
+var showLastResponse bool = false
+
+// sensitiveHeaders are the headers of which the values are never shown, in case the node or a proxy echoes them
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+}
+
+// renderLastResponse renders an overlay showing the status and headers of the most recent response of the node
+func renderLastResponse(width int, height int) {
+	p := widgets.NewParagraph()
+	p.Title = "| Last response of the node |"
+	p.Text = describeResponse(client.LastResponse())
+	p.SetRect(0, 0, width-1, height-1)
+	ui.Render(p)
+}
+
+// describeResponse lists the status and headers of the given response, with sensitive values redacted
+func describeResponse(response *ResponseInfo) string {
+	if response == nil {
+		return "nothing fetched from the node yet"
+	}
+	lines := []string{
+		fmt.Sprintf("%-14s GET %s", "Request:", response.URL),
+		fmt.Sprintf("%-14s %s", "Status:", response.Status),
+		fmt.Sprintf("%-14s %s", "Received:", response.Received.Format(time.RFC3339)),
+		"",
+	}
+	var names []string
+	for name := range response.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range response.Header[name] {
+			lines = append(lines, fmt.Sprintf("%s: %s", name, redactHeader(name, value)))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// redactHeader returns the value of the given header for display: sensitive headers and headers that contain the API
+// key (-apikey-param) are redacted
+func redactHeader(name string, value string) string {
+	if sensitiveHeaders[http.CanonicalHeaderKey(name)] {
+		return "(redacted)"
+	}
+	for _, values := range apiKeyParam {
+		for _, key := range values {
+			if key != "" && strings.Contains(value, key) {
+				return "(redacted)"
+			}
+		}
+	}
+	return value
+}
@@ -1,20 +1,25 @@
 package main
 
 import (
-	"bytes"
+	"bufio"
 	"context"
-	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"github.com/nuts-foundation/data-viewer/analyzers"
-	networkAPI "github.com/nuts-foundation/nuts-node/network/api/v1"
+	"github.com/nuts-foundation/nuts-node/crypto/hash"
+	"github.com/nuts-foundation/nuts-node/network/dag"
 	vdrAPI "github.com/nuts-foundation/nuts-node/vdr/api/v1"
 	"io"
 	"log"
 	"net/http"
 	"os"
-	"strconv"
+	"os/signal"
+	"sort"
 	"strings"
+	"syscall"
+	"time"
 
 	ui "github.com/gizak/termui/v3"
 	"github.com/gizak/termui/v3/widgets"
@@ -28,49 +33,259 @@ const (
 
 var showHelp bool = false
 var showDebug bool = false
-var hcursor int = 0
 var vcursor int = 0
 var lastPressed string
+var skipEmptyClocks bool = false
+var compactMode bool = false
+
+// wrapNavigation makes navigating left from the first transaction go to the head of the DAG and navigating right from
+// the head go to the first transaction, instead of stopping there
+var wrapNavigation bool = false
+
+// abbreviateHashes shows only the first characters of hashes, which is enough to tell them apart on narrow terminals.
+// It only affects what's shown: navigation always uses full hashes.
+var abbreviateHashes bool = false
+
+// rawPayload shows the decoded transaction data exactly as stored, instead of reformatting it as indented JSON
+var rawPayload bool = false
+
+// safeMode disables all side effects outside the terminal, like clipboard writes, so the tool can be used in shared
+// or recorded environments without leaking data
+var safeMode bool = false
+var errSafeMode = errors.New("disabled in safe mode")
+
+// dirty indicates the state changed since the last render, so the screen needs to be redrawn. Event handlers set it
+// whenever they change state, to avoid redrawing (and flickering) on events that don't change anything.
+var dirty bool = true
+
+// statusMessage is shown in the info line until the next key is pressed
+var statusMessage string
+
+// startReference is the transaction the viewer starts at, as copied with the n key: a position (N.M), a hash or both
+var startReference string
+
+// nodeURL is the base URL of the nuts node the viewer reads from
+var nodeURL = "http://127.0.0.1:1323"
+var client *Client
+
+// defaultMaxNodes is the default maximum number of transactions in a graph (-max-nodes)
+const defaultMaxNodes = 10000
+
+// backgroundUpdates receives the updates of work running in the background (see copyGraph), which the event loop applies
+// to the state: only the event loop changes the state, so it doesn't change while rendering
+var backgroundUpdates = make(chan func(), 10)
+
+// viewerContext is the context of all fetches of the viewer, which is canceled when it shuts down
+var viewerContext, cancelViewer = context.WithCancel(context.Background())
 
 func main() {
 	if len(os.Args) >= 3 && os.Args[1] == "analyze" {
 		nodeAddress := os.Getenv("NUTS_NODE_ADDRESS")
 		if len(nodeAddress) == 0 {
-			log.Panic("NUTS_NODE_ADDRESS not set")
-		}
-		vdrClient, err := vdrAPI.NewClient(nodeAddress)
-		if err != nil {
-			log.Panic(err)
-		}
-		networkClient, err := networkAPI.NewClient(nodeAddress)
-		if err != nil {
-			log.Panic(err)
+			log.Fatal("NUTS_NODE_ADDRESS not set")
 		}
 
 		switch os.Args[2] {
 		case "did-graph":
-			if len(os.Args) < 4 {
-				log.Panic("analyze did-graph requires a DID as argument")
+			flags := flag.NewFlagSet("did-graph", flag.ExitOnError)
+			tooltips := flags.Bool("tooltips", false, "add the DID document as tooltip to each node (e.g. for SVG output)")
+			format := flags.String("format", "dot", "output format: dot, plantuml, mermaid or edges (child parent pairs)")
+			htmlLabels := flags.Bool("html-labels", false, "render node labels as tables (Graphviz HTML-like labels)")
+			notePerLine := flags.Bool("note-per-line", false, "put each note on its own line of the node label")
+			plainRoots := flags.Bool("plain-roots", false, "don't render root transactions (without previous transactions) with a distinct shape")
+			signingKeys := flags.Bool("signing-keys", false, "add the key that signed the transaction to each node label")
+			reverseEdges := flags.Bool("reverse-edges", false, "point edges from a transaction to its previous transactions, instead of the other way around")
+			colorByDID := flags.Bool("color-by-did", false, "fill each node with a color derived from its DID")
+			palette := flags.String("palette", "", "comma-separated colors for -color-by-did (default: colorblind-friendly Okabe-Ito)")
+			clip := flags.Bool("clip", false, "also copy the output to the clipboard (OSC52)")
+			minLC := flags.Uint("min-lc", 0, "only include transactions from this lamport clock on")
+			since := flags.Uint("since", 0, "only include transactions from this lamport clock on, to see what changed recently (same as -min-lc)")
+			maxLC := flags.Uint("max-lc", 0, "only include transactions up to this lamport clock (0 means no limit)")
+			out := flags.String("out", "", "write the output to this file instead of stdout, a file ending in .html gets a page showing the graph")
+			watch := flags.Duration("watch", 0, "keep running and regenerate the output whenever the DAG grows, checking at this interval (e.g. 10s)")
+			stopAtDeactivation := flags.Bool("stop-at-deactivation", false, "leave out the transactions that follow a deactivation")
+			asOf := flags.String("as-of", "", "only include this transaction (a version of the DID document) and its history")
+			timeout := flags.Duration("timeout", 0, "maximum duration of the whole analysis (0 means no limit)")
+			timeoutPerTX := flags.Duration("timeout-per-tx", 0, "maximum duration of reading a single transaction (0 means no limit)")
+			followControllers := flags.Bool("follow-controllers", true, "also analyze the controllers of the DIDs (and the documents they control)")
+			includeUnreferenced := flags.Bool("include-unreferenced", false, "also scan the lamport clock window for transactions of the DIDs that aren't reachable (expensive)")
+			firstParent := flags.Bool("first-parent", false, "only follow the first previous transaction of every transaction, for a linear history without merged branches")
+			maxNodes := flags.Int("max-nodes", defaultMaxNodes, "abort if the graph grows beyond this number of transactions (0 means no limit)")
+			failOnEmpty := flags.Bool("fail-on-empty", false, "exit with status 1 if the graph contains no transactions")
+			flags.BoolVar(&safeMode, "safe", false, "disable all side effects like clipboard writes")
+			var headers headerFlags
+			flags.Var(&headers, "header", "add a header (key:value) to every request to the node, can be repeated")
+			registerHTTPFlags(flags)
+			_ = flags.Parse(os.Args[3:])
+			if flags.NArg() == 0 {
+				log.Fatal("analyze did-graph requires a DID as argument")
+			}
+			// Writing the output to a file is a side effect outside the terminal
+			if safeMode && *out != "" {
+				log.Fatalf("-out: %v", errSafeMode)
+			}
+			nodeAddress, err := setupNodeAccess(nodeAddress)
+			if err != nil {
+				log.Fatal(err)
 			}
-			output, err := analyzers.DIDDocumentGraphAnalyzer{
-				VDR:     vdrClient,
-				Network: networkClient,
-			}.Analyze(context.Background(), os.Args[3:])
+			vdrClient, err := vdrAPI.NewClient(nodeAddress, vdrAPI.WithHTTPClient(newHTTPClient()))
 			if err != nil {
-				log.Panic(err)
+				log.Fatal(err)
+			}
+			networkClient, err := newNetworkAPIClient(nodeAddress, newHTTPClient())
+			if err != nil {
+				log.Fatal(err)
+			}
+			if *since > *minLC {
+				*minLC = *since
+			}
+			// The page shows the graph itself, so it's not in any of the formats
+			if isHTMLFile(*out) {
+				*format = "html"
+			}
+			didOrTXs, err := readAnalyzerInput(flags.Args(), os.Stdin)
+			if err != nil {
+				log.Fatal(err)
+			}
+			analyzer := analyzers.NewDIDDocumentGraphAnalyzer(vdrClient, networkClient, headers.editors()...)
+			// Show progress on the terminal, but don't litter stderr when it's redirected
+			progress := isTerminal(os.Stderr)
+			options := analyzers.Options{MinLC: uint32(*minLC), MaxLC: uint32(*maxLC), StopAtDeactivation: *stopAtDeactivation,
+				TransactionTimeout: *timeoutPerTX, MaxNodes: *maxNodes, SkipControllers: !*followControllers,
+				IncludeUnreferenced: *includeUnreferenced, FirstParent: *firstParent}
+			if *asOf != "" {
+				if options.AsOf, err = hash.ParseHex(*asOf); err != nil {
+					log.Fatalf("invalid -as-of transaction: %v", err)
+				}
+			}
+			if progress {
+				options.Progress = func(nodes int, edges int) {
+					fmt.Fprintf(os.Stderr, "\rdiscovered %d transactions, %d edges", nodes, edges)
+				}
+			}
+			renderOptions := analyzers.RenderOptions{Tooltips: *tooltips, HTMLLabels: *htmlLabels, NotePerLine: *notePerLine,
+				ColorByDID: *colorByDID, PlainRoots: *plainRoots, ReverseEdges: *reverseEdges, SigningKeys: *signingKeys}
+			if *palette != "" {
+				renderOptions.Palette = strings.Split(*palette, ",")
+			}
+			graph, output, err := analyzeDIDGraph(analyzer, didOrTXs, options, *format, renderOptions, *timeout)
+			if progress {
+				// Clear the progress line
+				fmt.Fprint(os.Stderr, "\r\x1b[K")
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+			if err := writeOutput(*out, output); err != nil {
+				log.Fatal(err)
+			}
+			if *clip {
+				if err := copyToClipboard(output); err != nil {
+					log.Printf("output not copied to clipboard: %v", err)
+				}
+			}
+			if len(graph.Nodes) == 0 {
+				log.Print("warning: the graph is empty, no DID document transactions were found")
+				if *failOnEmpty {
+					os.Exit(1)
+				}
+			}
+			if *watch > 0 {
+				watchDAG(NewClient(nodeAddress), *watch, func() {
+					_, output, err := analyzeDIDGraph(analyzer, didOrTXs, options, *format, renderOptions, *timeout)
+					if progress {
+						fmt.Fprint(os.Stderr, "\r\x1b[K")
+					}
+					if err == nil {
+						err = writeOutput(*out, output)
+					}
+					if err != nil {
+						log.Printf("failed to regenerate the graph: %v", err)
+					}
+				})
 			}
-			fmt.Println(output)
 			os.Exit(0)
 		}
 	}
 
+	if len(os.Args) >= 2 && os.Args[1] == "get" {
+		runGet(os.Args[2:])
+		os.Exit(0)
+	}
+
+	if len(os.Args) >= 2 && os.Args[1] == "resolve" {
+		runResolve(os.Args[2:])
+		os.Exit(0)
+	}
+
+	if len(os.Args) >= 2 && os.Args[1] == "decode" {
+		runDecode(os.Args[2:])
+		os.Exit(0)
+	}
+
+	if len(os.Args) >= 2 && os.Args[1] == "signed-by" {
+		runSignedBy(os.Args[2:])
+		os.Exit(0)
+	}
+
+	if len(os.Args) >= 2 && os.Args[1] == "verify" {
+		runVerify(os.Args[2:])
+		os.Exit(0)
+	}
+
+	if len(os.Args) >= 2 && os.Args[1] == "histogram" {
+		runHistogram(os.Args[2:])
+		os.Exit(0)
+	}
+
+	flag.StringVar(&nodeURL, "node", nodeURL, "base URL of the nuts node")
+	registerHTTPFlags(flag.CommandLine)
+	flag.StringVar(&exportDir, "export-dir", exportDir, "directory the x key exports the view to")
+	flag.BoolVar(&exportANSI, "export-ansi", false, "keep colors when exporting the view (as ANSI escape codes)")
+	flag.BoolVar(&sortedKeys, "sort-keys", false, "sort the keys of JSON objects, for stable output")
+	flag.BoolVar(&canonicalDIDDocuments, "canonical", false, "show DID documents with their keys in the conventional order")
+	flag.BoolVar(&compactMode, "compact", false, "render without borders and titles to maximize the content area")
+	flag.BoolVar(&rawPayload, "raw-payload", false, "show the decoded data verbatim instead of as indented JSON")
+	flag.BoolVar(&safeMode, "safe", false, "disable all side effects like clipboard writes")
+	flag.StringVar(&transactionOrder, "sort", "node", "order of the transactions within a lamport clock: node, hash or time")
+	smoke := flag.Bool("smoke", false, "print the first transaction without starting the viewer and exit, to check the node can be read")
+	flag.StringVar(&startReference, "start", "", "transaction to start at: a position (N.M), a hash or both (as copied with the n key)")
+	flag.Parse()
+	if !validTransactionOrder(transactionOrder) {
+		log.Fatalf("invalid sort order: %s", transactionOrder)
+	}
+	var err error
+	if nodeURL, err = setupNodeAccess(nodeURL); err != nil {
+		log.Fatal(err)
+	}
+	if *smoke {
+		runSmokeTest()
+	}
+	client = NewClient(nodeURL)
+
 	// Setup termui which provides primitives for terminal-based UI applications
 	if err := ui.Init(); err != nil {
-		log.Fatalf("failed to initialize termui: %v", err)
+		fatalf("failed to initialize termui: %v", err)
 	}
+	uiActive = true
+
+	// termui handles Ctrl-C as key press, but signals (e.g. from kill) must restore the terminal too. This can't wait
+	// for the event loop, since that's blocked while fetching.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		log.Printf("got signal: %v", sig)
+		shutdown(128 + int(sig.(syscall.Signal)))
+	}()
 
-	// Upon returning from main perform teardown operations for termui
-	defer ui.Close()
+	// Restore the terminal when panicking, shutdown takes care of all other exits
+	defer func() {
+		if r := recover(); r != nil {
+			restoreTerminal()
+			panic(r)
+		}
+	}()
 
 	// Create channels for events from the UI as well as internal app events
 	uiEvents := ui.PollEvents()
@@ -79,8 +294,20 @@ func main() {
 	// Put a start event in the app events channel
 	appEvents <- StartEvent
 
+	// Go to the transaction to start at. When both its position and hash are given the hash is used, since positions
+	// may differ between nodes.
+	if fields := strings.Fields(startReference); len(fields) > 0 {
+		jumpTo(fields[len(fields)-1])
+	}
+
 	// Handle events as they occur
 	for {
+		// Background work is done step by step in between events, so it doesn't block handling them
+		var work chan struct{}
+		if timelineScan != nil {
+			work = ready
+		}
+
 		// Wait for an event to occur
 		select {
 		// Process UI events (keyboard/mouse input, etc.)
@@ -104,56 +331,387 @@ func main() {
 		// Process app events (startup etc.)
 		case event := <-appEvents:
 			log.Printf("got app event: %v", event)
+			dirty = true
+
+		// Apply the updates of work running in the background
+		case update := <-backgroundUpdates:
+			update()
+			dirty = true
+
+		// Find the next versions for the timeline
+		case <-work:
+			continueTimelineScan()
+			dirty = true
 		}
 
-		// Render the application content
-		render()
+		// Render the application content, but only when the state changed since the last render
+		if dirty {
+			render()
+			dirty = false
+			// Rendering loads the transaction if needed, so only now it's known which one is shown
+			noteViewedTransaction()
+		}
 	}
 }
 
-func resizeEventHandler(dimensions ui.Resize) {}
+// ready is a closed channel, so receiving from it never blocks. Selecting on it (instead of nil, which never is ready)
+// does background work when there are no events.
+var ready = func() chan struct{} {
+	c := make(chan struct{})
+	close(c)
+	return c
+}()
+
+// uiActive is set once termui took over the terminal, which must then be restored before exiting
+var uiActive bool = false
+
+// shutdown performs all teardown (restoring the terminal) and exits with the given code. Once the viewer is running,
+// it's the only way it exits.
+func shutdown(code int) {
+	cancelViewer()
+	restoreTerminal()
+	os.Exit(code)
+}
+
+// fatalf exits like log.Fatalf, but through shutdown. The terminal is restored before logging, otherwise the message
+// would be lost in the terminal UI.
+func fatalf(format string, args ...interface{}) {
+	restoreTerminal()
+	log.Printf(format, args...)
+	shutdown(1)
+}
+
+// restoreTerminal hands the terminal back from termui, if it took it over
+func restoreTerminal() {
+	if uiActive {
+		ui.Close()
+		uiActive = false
+	}
+}
+
+// maxRecentTransactions is the number of recently viewed transactions listed in the debug pane
+const maxRecentTransactions = 5
+
+// recentTransactions summarizes the most recently viewed transactions, most recent first
+var recentTransactions []string
+
+// lastViewedTransaction is the raw transaction that was shown last, to detect moving to another one
+var lastViewedTransaction string
+
+// noteViewedTransaction logs a summary of the current transaction and adds it to recentTransactions, if it changed
+// since the last time. Transactions that aren't loaded yet are noted when they are.
+func noteViewedTransaction() {
+	if dagSubIndex >= len(transactions[dagLamportClock]) {
+		return
+	}
+	rawTransaction := transactions[dagLamportClock][dagSubIndex]
+	if rawTransaction == lastViewedTransaction {
+		return
+	}
+	lastViewedTransaction = rawTransaction
+	summary := summarizeTransaction(client, rawTransaction)
+	log.Printf("viewing %s", summary)
+	recentTransactions = append([]string{summary}, recentTransactions...)
+	if len(recentTransactions) > maxRecentTransactions {
+		recentTransactions = recentTransactions[:maxRecentTransactions]
+	}
+}
+
+// describeCache summarizes what the viewer holds in memory, which is all it caches (nothing is cached on disk)
+func describeCache() string {
+	cachedTransactions, cachedPayloads, size := client.CacheSize()
+	return fmt.Sprintf("cache: %d lamport clocks, %d transactions, %d payloads, %d KiB (in memory)",
+		len(transactions), cachedTransactions, cachedPayloads, (size+1023)/1024)
+}
+
+// isTerminal returns whether the given file is a terminal (character device), rather than e.g. a pipe or regular file
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// headerFlags collects the values of a repeatable -header key:value flag
+type headerFlags []string
+
+func (h *headerFlags) String() string {
+	return strings.Join(*h, ", ")
+}
+
+func (h *headerFlags) Set(value string) error {
+	if key, _, ok := strings.Cut(value, ":"); !ok || strings.TrimSpace(key) == "" {
+		return fmt.Errorf("invalid header %q, expected key:value", value)
+	}
+	*h = append(*h, value)
+	return nil
+}
+
+// editors returns a request editor per header, in the order they were specified. A header never replaces an
+// Authorization header that's already set, so it can't clobber authentication.
+func (h headerFlags) editors() []analyzers.RequestEditorFn {
+	var result []analyzers.RequestEditorFn
+	for _, header := range h {
+		key, value, _ := strings.Cut(header, ":")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		result = append(result, func(_ context.Context, req *http.Request) error {
+			if strings.EqualFold(key, "Authorization") && req.Header.Get("Authorization") != "" {
+				return nil
+			}
+			req.Header.Set(key, value)
+			return nil
+		})
+	}
+	return result
+}
+
+// analyzeDIDGraph analyzes the given DIDs and/or TX references and renders the resulting graph in the given format.
+// The analysis may take at most the given timeout, unless it's 0.
+func analyzeDIDGraph(analyzer *analyzers.DIDDocumentGraphAnalyzer, didOrTXs []string, options analyzers.Options, format string, renderOptions analyzers.RenderOptions, timeout time.Duration) (*analyzers.Graph, string, error) {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	graph, err := analyzer.Analyze(ctx, didOrTXs, options)
+	if errors.Is(err, analyzers.ErrTooManyNodes) {
+		return nil, "", fmt.Errorf("%w; limit the analysis using -min-lc, -max-lc or -as-of, or raise -max-nodes", err)
+	} else if err != nil {
+		return nil, "", err
+	}
+	var output string
+	if format == "html" {
+		output, err = renderGraphPage(graph, "DID document graph of "+strings.Join(didOrTXs, ", "), renderOptions)
+	} else {
+		output, err = graph.Render(format, renderOptions)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return graph, output, nil
+}
+
+// writeOutput writes the output of a subcommand to the given file, or to stdout if no file is given. Writing files is
+// refused in safe mode.
+func writeOutput(file string, output string) error {
+	if file == "" {
+		fmt.Println(output)
+		return nil
+	}
+	if safeMode {
+		return errSafeMode
+	}
+	return os.WriteFile(file, []byte(output+"\n"), 0644)
+}
+
+// readAnalyzerInput collects the DIDs and/or TX references to analyze from the command-line arguments. An argument
+// of "-" reads a newline-separated list from stdin instead, skipping blank lines and comments starting with "#".
+func readAnalyzerInput(args []string, stdin io.Reader) ([]string, error) {
+	var result []string
+	for _, arg := range args {
+		if arg != "-" {
+			result = append(result, arg)
+			continue
+		}
+		scanner := bufio.NewScanner(stdin)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			result = append(result, line)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read stdin: %w", err)
+		}
+	}
+	return result, nil
+}
+
+func resizeEventHandler(dimensions ui.Resize) {
+	dirty = true
+}
 
 func mouseEventHandler(position ui.Mouse) {}
 
 var keyboardReadLineBuffer string
 
 func keyboardEventHandler(pressed string) {
+	// Clearing a shown status message changes what's on screen
+	if statusMessage != "" {
+		statusMessage = ""
+		dirty = true
+	}
+
 	if pressed == "#" {
 		keyboardReadLineBuffer = pressed
-	} else if keyboardReadLineBuffer != "" && strings.Contains("0123456789", pressed) {
+		dirty = true
+	} else if keyboardReadLineBuffer == "#" && (pressed == "+" || pressed == "-") {
+		// A leading + or - makes it a jump relative to the current lamport clock
 		keyboardReadLineBuffer += pressed
-	} else if keyboardReadLineBuffer != "" && pressed == "<Enter>" && !strings.HasSuffix(keyboardReadLineBuffer, "\n") {
-		keyboardReadLineBuffer += "\n"
-	} else {
+		dirty = true
+	} else if keyboardReadLineBuffer != "" && len(pressed) == 1 && strings.Contains("0123456789abcdef.", pressed) {
+		// Digits, dots and hex digits, to enter a lamport clock, a position (N.M) or a transaction hash
+		keyboardReadLineBuffer += pressed
+		dirty = true
+	} else if keyboardReadLineBuffer != "" && pressed == "<Escape>" {
 		keyboardReadLineBuffer = ""
+		dirty = true
+	} else if keyboardReadLineBuffer != "" && (pressed == "<Backspace>" || pressed == "<C-<Backspace>>") {
+		// Terminals send either of both for backspace. Deleting the # cancels entering a transaction number.
+		keyboardReadLineBuffer = keyboardReadLineBuffer[:len(keyboardReadLineBuffer)-1]
+		dirty = true
+	} else if keyboardReadLineBuffer != "" && pressed == "<Enter>" {
+		// Handle the user manually entering a transaction number
+		jumpTo(strings.TrimLeft(keyboardReadLineBuffer, "#"))
+		keyboardReadLineBuffer = ""
+		dirty = true
+	} else {
+		if keyboardReadLineBuffer != "" {
+			keyboardReadLineBuffer = ""
+			dirty = true
+		}
 
-		if pressed == "q" || pressed == "Q" {
-			ui.Close()
-			os.Exit(0)
+		// Any key bound to an action changes the state, other keys are ignored
+		handled := true
+		if pressed == "q" || pressed == "Q" || pressed == "<C-c>" {
+			shutdown(0)
 		} else if pressed == "?" || pressed == "<F1>" {
 			showHelp = !showHelp
 		} else if pressed == "ß" /* Option-D */ {
 			showDebug = !showDebug
+		} else if pressed == "s" {
+			skipEmptyClocks = !skipEmptyClocks
+		} else if pressed == "w" {
+			wrapNavigation = !wrapNavigation
+		} else if pressed == "c" {
+			compactMode = !compactMode
+		} else if pressed == "+" || pressed == "=" {
+			changeFetchWindow(1)
+		} else if pressed == "-" {
+			changeFetchWindow(-1)
+		} else if pressed == "h" {
+			abbreviateHashes = !abbreviateHashes
+		} else if pressed == "o" {
+			sortedKeys = !sortedKeys
+		} else if pressed == "k" {
+			canonicalDIDDocuments = !canonicalDIDDocuments
+		} else if pressed == "v" {
+			rawPayload = !rawPayload
+		} else if pressed == "i" {
+			showAbout = !showAbout
+		} else if pressed == "P" {
+			showPayload = !showPayload
+		} else if pressed == "H" {
+			showLastResponse = !showLastResponse
+		} else if pressed == "t" {
+			toggleTimeline()
+		} else if timelineScan != nil && pressed == "<Escape>" {
+			stopTimelineScan()
+		} else if showTimeline && pressed == "<Up>" {
+			moveTimelineCursor(-1)
+		} else if showTimeline && pressed == "<Down>" {
+			moveTimelineCursor(1)
+		} else if showTimeline && pressed == "<Enter>" {
+			goToTimelineSelection()
+		} else if pressed == "[" {
+			jumpToVersion(-1)
+		} else if pressed == "]" {
+			jumpToVersion(1)
+		} else if pressed == "{" {
+			jumpToCreation()
+		} else if showAbout && len(pressed) == 1 && pressed >= "1" && pressed <= "9" {
+			goToController(int(pressed[0] - '0'))
+		} else if pressed == "d" {
+			showResolved = !showResolved
+		} else if pressed == "x" {
+			if path, err := exportView(); err != nil {
+				statusMessage = fmt.Sprintf("export: %v", err)
+			} else {
+				statusMessage = fmt.Sprintf("exported the view to %s", path)
+			}
+		} else if pressed == "r" {
+			retryFailedFetch()
+		} else if pressed == "C" {
+			clearCaches()
+			statusMessage = "cleared the cache, fetching everything from the node again"
+		} else if pressed == "y" {
+			copyTransaction()
+		} else if pressed == "n" {
+			copyPosition()
+		} else if pressed == "p" {
+			copyPayload()
+		} else if pressed == "G" {
+			copyGraphCommand()
+		} else if pressed == "D" {
+			copyGraph()
+		} else if pressed == "Y" {
+			copyHeader()
 		} else if pressed == "<Left>" {
-			hcursor--
+			move(-1)
 		} else if pressed == "<Right>" {
-			hcursor++
+			move(1)
 		} else if pressed == "<Up>" {
 			vcursor--
 		} else if pressed == "<Down>" {
 			vcursor++
+		} else {
+			handled = false
+		}
+		if handled {
+			dirty = true
 		}
 	}
 
 	lastPressed = pressed
+
+	// The debug screen shows the last pressed key
+	if showDebug {
+		dirty = true
+	}
 }
 
+// minTerminalWidth and minTerminalHeight are the smallest terminal dimensions (in characters) the layout fits in
+const minTerminalWidth, minTerminalHeight = 20, 5
+
 func render() {
 	// Clear any existing content on the terminal
 	ui.Clear()
 
+	// Don't attempt the layout on tiny terminals, since the widgets would get degenerate dimensions
+	if width, height := ui.TerminalDimensions(); width < minTerminalWidth || height < minTerminalHeight {
+		p := newBorderlessParagraph()
+		p.Text = "terminal too small"
+		p.SetRect(0, 0, width, height)
+		ui.Render(p)
+		return
+	}
+
 	renderDAG()
 
+	// Optionally compare the DID document in the current transaction with its resolved (current) state
+	if showResolved && dagSubIndex < len(transactions[dagLamportClock]) {
+		width, height := ui.TerminalDimensions()
+		renderResolved(transactions[dagLamportClock][dagSubIndex], width, height)
+	}
+
+	// Optionally show everything known about the current transaction on top of it
+	if showAbout && dagSubIndex < len(transactions[dagLamportClock]) {
+		width, height := ui.TerminalDimensions()
+		renderAbout(transactions[dagLamportClock][dagSubIndex], width, height)
+	}
+
+	// Optionally show the last response of the node on top of it
+	if showLastResponse {
+		width, height := ui.TerminalDimensions()
+		renderLastResponse(width, height)
+	}
+
+	// Optionally list the versions of the DID document on top of it
+	if showTimeline {
+		width, height := ui.TerminalDimensions()
+		renderTimeline(width, height)
+	}
+
 	// Optionally show the help screen on top of the app
 	if showHelp {
 		// Determine the size of the terminal in characters
@@ -164,21 +722,61 @@ func render() {
 		p.Text = "q | Q          - quit\n" +
 			"? | <F1>       - show/hide help\n" +
 			"\n" +
-			"#𝑁<Enter>     - select transaction number 𝑁 \n" +
+			"#𝑁<Enter>     - select transaction number 𝑁 (or position 𝑁.𝑀, or a transaction hash)\n" +
+			"<Escape>       - cancel entering a transaction number (<Backspace> deletes a character)\n" +
+			"#+𝑁 | #-𝑁<Enter> - move 𝑁 lamport clocks forward/back\n" +
+			"s              - skip/show empty lamport clocks when navigating\n" +
+			"w              - toggle wrapping around at the first transaction and the head of the DAG\n" +
+			"c              - toggle compact mode (no borders)\n" +
+			"+ | -          - fetch more/fewer lamport clocks at once\n" +
+			"h              - toggle showing hashes abbreviated\n" +
+			"o              - toggle sorting the keys of JSON objects\n" +
+			"k              - toggle showing DID documents with their keys in the conventional order\n" +
+			"v              - toggle showing the data verbatim (not indented)\n" +
+			"r              - retry a failed fetch\n" +
+			"C              - clear the cache and fetch everything from the node again\n" +
+			"i              - show/hide everything known about the transaction\n" +
+			"P              - show the payload (fetched from the node) instead of the header, verifying its hash\n" +
+			"H              - show/hide the status and headers of the last response of the node\n" +
+			"1-9            - in the about view: go to the latest version of a controller of the DID document\n" +
+			"[ | ]          - go to the previous/next version of the DID document\n" +
+			"{              - go to the transaction that created the DID document\n" +
+			"t              - show/hide the timeline of the DID document (<Up>/<Down> select, <Enter> goes to it,\n" +
+			"                 <Escape> stops looking for later versions)\n" +
+			"d              - compare the DID document with its current (resolved) state\n" +
 			"\n" +
-			"y              - copy raw transaction to clipboard (OSC52)" +
+			"x              - export the view to a text file\n" +
+			"Y              - copy decoded header to clipboard (OSC52)\n" +
+			"n              - copy the position (N.M) and hash of the transaction to clipboard (OSC52)\n" +
+			"p              - copy payload to clipboard, formatted for its content type (OSC52)\n" +
+			"y              - copy raw transaction to clipboard (OSC52)\n" +
+			"G              - copy a command rendering the graph of the DID document with Graphviz (OSC52)\n" +
+			"D              - analyze the graph of the DID document and copy it as dot (OSC52)\n" +
 			"Home | g       - go to transaction 0.0\n" // TODO: Implement this
 		p.SetRect(0, 0, width-1, height-1)
 		ui.Render(p)
 	}
 
+	// Show what's typed while entering a transaction number, on top of everything else
+	if keyboardReadLineBuffer != "" {
+		width, height := ui.TerminalDimensions()
+		p := widgets.NewParagraph()
+		p.Title = "| Go to transaction (Enter to jump, Escape to cancel) |"
+		p.Text = keyboardReadLineBuffer + "_"
+		p.SetRect(0, height-4, width, height-1)
+		ui.Render(p)
+	}
+
 	if showDebug {
 		// Determine the size of the terminal in characters
 		width, height := ui.TerminalDimensions()
 		p := widgets.NewParagraph()
 		p.Title = "| Debug |"
 		p.Text = "test keyboard: " + lastPressed + "\n" +
-			"test readline: " + keyboardReadLineBuffer
+			"test readline: " + keyboardReadLineBuffer + "\n" +
+			fmt.Sprintf("skip empty clocks: %v", skipEmptyClocks) + "\n" +
+			describeCache() + "\n" +
+			"recently viewed:\n  " + strings.Join(recentTransactions, "\n  ")
 		p.SetRect(0, 0, width-1, height-1)
 		ui.Render(p)
 	}
@@ -191,71 +789,136 @@ var dagLamportClock int
 var dagSubIndex int
 var dagMaxLamportClock int = 9999 // TODO: This must not be hard coded
 
-func renderDAG() {
-	// Handle the user manually entering a transaction number
-	if strings.HasSuffix(keyboardReadLineBuffer, "\n") {
-		s := strings.TrimLeft(strings.TrimRight(keyboardReadLineBuffer, "\n"), "#")
-		if n, err := strconv.ParseInt(s, 10, 32); err == nil {
-			dagLamportClock = int(n)
-			dagSubIndex = 0
-		} else {
-			log.Panicf("strconv error: %v", err)
-		}
-		keyboardReadLineBuffer = ""
+// fetchErrorHint returns advice for the user on the given fetch error, depending on its kind
+func fetchErrorHint(err error) string {
+	switch {
+	case errors.Is(err, ErrTimeout):
+		return "the node didn't respond in time, press r to retry"
+	case errors.Is(err, ErrNotFound):
+		return "the node doesn't provide the transaction list, check the node URL (-node)"
+	case errors.Is(err, ErrDecode):
+		return "the response of the node isn't a list of transactions, so retrying (r) likely won't help"
+	default:
+		return "press r to retry"
 	}
+}
 
-	// Handle the user browsing the DAG
-	if hcursor != 0 {
-		// Handle the user navigating left
-		if hcursor < 0 {
-			// Decrement the sub index within a particular lamport clock if possible
-			if dagSubIndex > 0 {
-				dagSubIndex--
+// fetchErrors holds the errors of failed fetches per lamport clock, which are shown instead of the transactions
+var fetchErrors map[int]error
 
-				// Otherwise decrement the lamport clock if possible, resetting the sub index
-			} else if dagLamportClock > 0 {
-				dagLamportClock--
+// lastFailedClock is the lamport clock of which fetching failed last, or -1 if there's none
+var lastFailedClock int = -1
 
-				// Reset the sub index to select the "rightmost" transaction within the
-				// new lamport clock
-				// TODO: FIX BUG HERE: dagSubIndex = len(transactions[dagLamportClock])-1
-				dagSubIndex = 0 // TODO: Temporary hack for bug ^^
-			}
+// transactionOrder is the order of the transactions within a lamport clock (see sortTransactions)
+var transactionOrder string = "node"
 
-			// Handle the user navigating right
-		} else {
-			// Increment the sub index within a particular lamport clock if possible
-			if dagSubIndex+1 < len(transactions[dagLamportClock]) {
-				dagSubIndex++
+// validTransactionOrder returns whether the given order is supported by sortTransactions
+func validTransactionOrder(order string) bool {
+	return order == "node" || order == "hash" || order == "time"
+}
 
-				// Otherwise increment the lamport clock if possible, resetting the sub index
-			} else if dagLamportClock < dagMaxLamportClock {
-				dagLamportClock++
+// jumpTo moves to the transaction the user entered: a transaction number (N, N.M, +N or -N) or hash
+func jumpTo(input string) {
+	if ref, err := hash.ParseHex(input); err == nil {
+		rawTransaction, err := client.Transaction(viewerContext, ref)
+		if err != nil {
+			statusMessage = fmt.Sprintf("failed to get transaction %s: %v", ref, err)
+		} else if tx, err := dag.ParseTransaction([]byte(rawTransaction)); err != nil {
+			statusMessage = fmt.Sprintf("failed to parse transaction %s: %v", ref, err)
+		} else {
+			goToTransaction(tx)
+		}
+		return
+	}
+	if to, err := newNavigator().jump(position{dagLamportClock, dagSubIndex}, input); err != nil {
+		statusMessage = err.Error()
+	} else {
+		dagLamportClock, dagSubIndex = to.clock, to.subIndex
+	}
+}
 
-				// Reset the sub index to select the "leftmost" transaction within the
-				// new lamport clock
-				dagSubIndex = 0
-			}
+// move moves one transaction left (direction < 0) or right (direction > 0) when the user browses the DAG
+func move(direction int) {
+	from := position{dagLamportClock, dagSubIndex}
+	to, err := newNavigator().step(from, direction)
+	if wrapNavigation {
+		if wrapped, ok := wrapAround(from, direction); ok {
+			to, err = wrapped, nil
 		}
+	}
+	if err != nil {
+		statusMessage = err.Error()
+	}
+	dagLamportClock, dagSubIndex = to.clock, to.subIndex
+}
 
-		// Reset the hcursor to 0 so that future navigation can be handled properly
-		hcursor = 0
+// headClock is the highest lamport clock of the DAG when it was last queried (see refreshHead), or -1 if it hasn't
+// been. The DAG only grows, so wrapAround only queries it again when moving right beyond it or into an empty clock.
+var headClock = -1
+
+// wrapAround returns where moving in the given direction wraps around to, if the given position is at the end of the
+// DAG in that direction: from the first transaction to the head and vice versa. The head is asked of the node, since
+// the DAG keeps growing (see headClock).
+func wrapAround(from position, direction int) (position, bool) {
+	if direction < 0 && (from.clock > 0 || from.subIndex > 0) {
+		return from, false
+	}
+	if direction > 0 && from.subIndex+1 < len(transactions[from.clock]) {
+		return from, false
+	}
+	// Moving right within the known DAG doesn't need the head
+	if direction > 0 && from.clock < headClock && (viewerTransactions{}).TransactionCount(from.clock+1) > 0 {
+		return from, false
+	}
+	head, err := refreshHead()
+	if err != nil {
+		statusMessage = fmt.Sprintf("not wrapping around, failed to determine the head of the DAG: %v", err)
+		return from, false
+	}
+	if direction < 0 {
+		return position{clock: head.HighestClock}, true
 	}
+	if from.clock >= head.HighestClock {
+		return position{}, true
+	}
+	return from, false
+}
 
+// renderDAG renders the current transaction. It only presents the state, actions are handled by keyboardEventHandler.
+func renderDAG() {
 	// If needed load the transactions for the desired lamport clock
-	if _, ok := transactions[dagLamportClock]; !ok {
-		// Load the transactions for this lamport clock into the transactions map
-		transactions[dagLamportClock] = fetchTransactionsInRange(dagLamportClock, dagLamportClock+1)
+	loadTransactions(dagLamportClock)
+
+	// Create a new paragraph UI widget, which can render arbitrary text
+	p := widgets.NewParagraph()
+
+	// Determine the size of the terminal in characters
+	width, height := ui.TerminalDimensions()
+
+	// Show why loading the transactions failed, offering to retry
+	if err, failed := fetchErrors[dagLamportClock]; failed {
+		p.Title = fmt.Sprintf("| Transaction %d |", dagLamportClock)
+		p.Text = fmt.Sprintf("[failed to load transactions at lamport clock %d: %v](fg:red)\n\n%s", dagLamportClock, err, fetchErrorHint(err))
+		setContentRect(p, width, height-1)
+		ui.Render(p)
+		renderInfoLine("", width, height)
+		return
 	}
 
-	// Support OSC52 clipboard copy of raw transaction data
-	if lastPressed == "y" {
-		print("\033]52;c;" + base64.StdEncoding.EncodeToString([]byte(transactions[dagLamportClock][dagSubIndex])) + "\a")
-		lastPressed = "" // TODO: This should not be necessary and is a bit hacky
+	// Show a placeholder for lamport clocks without any transactions (a gap in the DAG)
+	if len(transactions[dagLamportClock]) == 0 {
+		p.Title = fmt.Sprintf("| Transaction %d |", dagLamportClock)
+		p.Text = fmt.Sprintf("No transactions at lamport clock %d (press s to skip empty clocks)", dagLamportClock)
+		setContentRect(p, width, height-1)
+		ui.Render(p)
+		renderInfoLine("", width, height)
+		return
 	}
 
-	// Create a new paragraph UI widget, which can render arbitrary text
-	p := widgets.NewParagraph()
+	// The sub index can be out of range when the transactions were reloaded (e.g. after a retry), select the last one
+	if dagSubIndex >= len(transactions[dagLamportClock]) {
+		dagSubIndex = len(transactions[dagLamportClock]) - 1
+	}
 
 	// Show the transaction # as a decimal, so that the lamport clock and sub index are visible
 	if len(transactions[dagLamportClock]) > 1 {
@@ -265,72 +928,372 @@ func renderDAG() {
 		p.Title = fmt.Sprintf("| Transaction %d |", dagLamportClock)
 	}
 
-	// Split the transaction on dots (".") in which the first part is the base64 encoded JSON data
-	transactionParts := strings.Split(transactions[dagLamportClock][dagSubIndex], ".")
+	// Decode the header of the transaction and render any decode errors instead. The payload isn't part of the
+	// transaction, so showing it takes a separate fetch.
+	header := decodeTransaction(transactions[dagLamportClock][dagSubIndex])
+	if showPayload {
+		p.Title = strings.TrimSuffix(p.Title, " |") + " payload (fetched separately) |"
+		p.Text = payloadText(transactions[dagLamportClock][dagSubIndex])
+	} else if header.err != nil {
+		p.Text = header.err.Error()
+	} else if rawPayload {
+		p.Text = string(header.raw)
+	} else if sortedKeys {
+		p.Text, _ = indentJSON(maybeSortKeys(header.raw))
+	} else {
+		p.Text = header.indented
+	}
+
+	// Use all available terminal space for the render, except for the info line at the bottom
+	setContentRect(p, width, height-1)
 
-	// If the transaction split was successful then perform base64 and JSON decoding
-	if transactionParts != nil {
-		// Decode the raw base64 data of the transaction
-		if rawJSON, err := base64.RawStdEncoding.DecodeString(transactionParts[0]); err == nil {
-			// Nicely format and indent the JSON
-			var prettyJSON bytes.Buffer
-			if err := json.Indent(&prettyJSON, rawJSON, "", "    "); err == nil {
-				p.Text = prettyJSON.String()
-			} else {
-				p.Text = err.Error()
-			}
-		} else {
-			// Render any decode errors
-			p.Text = err.Error()
+	// Print the UI to the terminal
+	ui.Render(p)
+
+	renderInfoLine(transactions[dagLamportClock][dagSubIndex], width, height)
+}
+
+// setContentRect sizes the given paragraph to fill the area from the top of the terminal down to the given height. In
+// compact mode the border is dropped and the title is rendered as a minimal one-line header above the text instead.
+func setContentRect(p *widgets.Paragraph, width int, height int) {
+	// Remember what's shown, for exporting the view
+	shownTitle, shownText, shownInfoLine = p.Title, p.Text, ""
+	if !compactMode {
+		p.SetRect(0, 0, width, height)
+		return
+	}
+	header := newBorderlessParagraph()
+	header.Text = strings.Trim(p.Title, "| ")
+	header.TextStyle = ui.NewStyle(ui.ColorClear, ui.ColorClear, ui.ModifierBold)
+	header.SetRect(0, 0, width, 1)
+	ui.Render(header)
+
+	p.Title = ""
+	p.Border = false
+	p.PaddingLeft, p.PaddingTop, p.PaddingRight, p.PaddingBottom = -1, -1, -1, -1
+	p.SetRect(0, 1, width, height)
+}
+
+// renderInfoLine renders a single line at the bottom of the terminal with information about the given transaction,
+// which may be empty if there's none
+func renderInfoLine(rawTransaction string, width int, height int) {
+	p := newBorderlessParagraph()
+	if statusMessage != "" {
+		p.Text = fmt.Sprintf("[%s](fg:yellow)", statusMessage)
+	} else if rawTransaction == "" {
+		// Nothing to tell about a transaction that isn't there
+	} else if tx, err := dag.ParseTransaction([]byte(rawTransaction)); err == nil {
+		// Verifying the payload hash takes the payload, which is only fetched when it's shown (P or i): otherwise
+		// browsing would fetch the payload of every transaction
+		if showPayload || showAbout {
+			verifyPayloadHash(tx)
 		}
+		p.Text = fmt.Sprintf("%s | %s | payload %s", displayHash(tx.Ref()), tx.PayloadType(), cachedPayloadVerification(tx))
+		if isHead(tx) {
+			p.Text = "[HEAD](fg:cyan,mod:bold) | " + p.Text
+		}
+	} else {
+		p.Text = fmt.Sprintf("[failed to parse transaction: %v](fg:red)", err)
+	}
+	if modes := modeLine(); modes != "" {
+		p.Text = modes + " | " + p.Text
+	}
+	// Remind the user the responses of the node end up on disk
+	if dumpDir != "" {
+		p.Text = fmt.Sprintf("[dumping to %s](fg:magenta) | ", dumpDir) + p.Text
+	}
+	shownInfoLine = p.Text
+	p.SetRect(0, height-1, width, height)
+	ui.Render(p)
+}
+
+// abbreviatedHashLength is the number of characters of a hash shown when abbreviateHashes is enabled
+const abbreviatedHashLength = 8
+
+// displayHash returns the given hash for display, abbreviated if abbreviateHashes is enabled
+func displayHash(ref hash.SHA256Hash) string {
+	if abbreviateHashes {
+		return ref.String()[:abbreviatedHashLength]
+	}
+	return ref.String()
+}
+
+// modeLine summarizes the active toggles and options that change what's shown, e.g. [SKIP] [RAW] [sort:hash]
+func modeLine() string {
+	var modes []string
+	if skipEmptyClocks {
+		modes = append(modes, "[SKIP]")
+	}
+	if compactMode {
+		modes = append(modes, "[COMPACT]")
+	}
+	if wrapNavigation {
+		modes = append(modes, "[WRAP]")
+	}
+	if rawPayload {
+		modes = append(modes, "[RAW]")
+	}
+	if sortedKeys {
+		modes = append(modes, "[SORTED]")
+	}
+	if canonicalDIDDocuments {
+		modes = append(modes, "[CANONICAL]")
+	}
+	if safeMode {
+		modes = append(modes, "[SAFE]")
+	}
+	if abbreviateHashes {
+		modes = append(modes, "[SHORT]")
+	}
+	if transactionOrder != "node" {
+		modes = append(modes, "[sort:"+transactionOrder+"]")
+	}
+	if fetchWindow > 1 {
+		modes = append(modes, fmt.Sprintf("[window:%d]", fetchWindow))
+	}
+	// Separated by spaces, since termui mistakes "][" for style markup
+	return strings.Join(modes, " ")
+}
+
+// newBorderlessParagraph returns a paragraph without border of which the text uses the complete rect
+func newBorderlessParagraph() *widgets.Paragraph {
+	p := widgets.NewParagraph()
+	p.Border = false
+	// termui always reserves room for the border, negative padding gives that room back to the text
+	p.PaddingLeft, p.PaddingTop, p.PaddingRight, p.PaddingBottom = -1, -1, -1, -1
+	return p
+}
+
+// payloadVerifications caches the (styled) outcome of verifying the payload of a transaction, keyed by transaction reference
+var payloadVerifications map[hash.SHA256Hash]string
+
+// cachedPayloadVerification returns the outcome of verifying the payload hash of the transaction like
+// verifyPayloadHash, but only if its payload was fetched already. Otherwise, it's shown as unverified.
+func cachedPayloadVerification(tx dag.Transaction) string {
+	if result, ok := payloadVerifications[tx.Ref()]; ok {
+		return result
+	}
+	if _, ok := client.cachedPayload(tx.Ref()); ok {
+		return verifyPayloadHash(tx)
+	}
+	return "[unverified](fg:white)"
+}
+
+// verifyPayloadHash checks whether the hash of the transaction's payload matches the payload hash committed in the
+// transaction header and returns a green check or red cross for display in the info line. It fetches the payload if
+// it isn't cached yet.
+func verifyPayloadHash(tx dag.Transaction) string {
+	if result, ok := payloadVerifications[tx.Ref()]; ok {
+		return result
+	}
+	var result string
+	if payload, err := loadPayload(tx.Ref()); err != nil {
+		result = fmt.Sprintf("[? %v](fg:yellow)", err)
+	} else if hash.SHA256Sum(payload).Equals(tx.PayloadHash()) {
+		result = "[✔ hash verified](fg:green)"
 	} else {
-		p.Text = "error: string split failed"
+		result = "[✘ hash mismatch](fg:red)"
 	}
+	payloadVerifications[tx.Ref()] = result
+	return result
+}
 
-	// Determine the size of the terminal in characters
-	width, height := ui.TerminalDimensions()
+// decodedHeader is the outcome of decoding the header of a single transaction. A transaction that fails to decode only
+// carries its error, so it doesn't affect the other transactions at the same lamport clock.
+type decodedHeader struct {
+	raw      []byte
+	indented string
+	err      error
+}
 
-	// Use all available terminal space for the render
-	p.SetRect(0, 0, width, height)
+// decodedHeaders caches the decoded headers, keyed by raw transaction
+var decodedHeaders map[string]decodedHeader
 
-	// Print the UI to the terminal
-	ui.Render(p)
+// decodeTransaction returns the decoded header of the given raw transaction, decoding it if it isn't cached yet
+func decodeTransaction(rawTransaction string) decodedHeader {
+	if result, ok := decodedHeaders[rawTransaction]; ok {
+		return result
+	}
+	var result decodedHeader
+	result.raw, result.err = decodeRawHeader(rawTransaction)
+	if result.err == nil {
+		result.indented, result.err = indentJSON(result.raw)
+	}
+	decodedHeaders[rawTransaction] = result
+	return result
 }
 
-// fetchTransactionsInRange returns the transactions where start <= lamport clock < end
-func fetchTransactionsInRange(start int, end int) []string {
-	// Build the URL and place the start/end of the lamport clock range in the query string
-	url := fmt.Sprintf("http://127.0.0.1:1323/internal/network/v1/transaction?start=%d&end=%d", start, end)
+// payloadErrors keeps why fetching payloads failed, keyed by transaction reference, so rendering doesn't fetch them
+// again until the user retries
+var payloadErrors map[hash.SHA256Hash]error
 
-	// Call the API endpoint
-	response, err := http.Get(url)
+// showPayload shows the payload of the transaction instead of its header
+var showPayload bool = false
 
-	// If there is a response with a body ensure it is deallocated later
-	if response != nil && response.Body != nil {
-		defer response.Body.Close()
+// payloadText returns the payload of the given raw transaction for display, or why it couldn't be loaded
+func payloadText(rawTransaction string) string {
+	tx, err := dag.ParseTransaction([]byte(rawTransaction))
+	if err != nil {
+		return fmt.Sprintf("failed to parse transaction: %v", err)
+	}
+	payload, err := loadPayload(tx.Ref())
+	if err != nil {
+		return fmt.Sprintf("[failed to load the payload of transaction %s: %v](fg:red)\n\npress r to retry", tx.Ref(), err)
 	}
+	if rawPayload || !json.Valid(payload) {
+		return string(payload)
+	}
+	payload = maybeSortKeys(payload)
+	if canonicalDIDDocuments && tx.PayloadType() == didDocumentType {
+		if canonical, err := canonicalizeDIDDocument(payload); err == nil {
+			payload = canonical
+		}
+	}
+	text, _ := indentJSON(payload)
+	return text
+}
 
-	// If an error occurred then report an error condition
+// loadPayload returns the payload of the transaction with the given reference. Payloads are cached by the client and
+// failures here, so rendering (e.g. after changing how things are shown) only hits the node for new transactions.
+func loadPayload(ref hash.SHA256Hash) ([]byte, error) {
+	if err, failed := payloadErrors[ref]; failed {
+		return nil, err
+	}
+	payload, err := client.TransactionPayload(viewerContext, ref)
 	if err != nil {
-		log.Panicf("HTTP request failed: %v", err)
+		payloadErrors[ref] = err
 	}
+	return payload, err
+}
 
-	// Read the response body contents, risking memory allocation issues
-	body, err := io.ReadAll(response.Body)
+// fetchWindow is the number of lamport clocks fetched at once when loading transactions: wider windows mean fewer
+// requests when scanning through the DAG, narrower windows less load on the node
+var fetchWindow = 1
+
+// changeFetchWindow widens (direction > 0) or narrows the fetch window, within 1 and maxBatchClocks
+func changeFetchWindow(direction int) {
+	if direction > 0 {
+		fetchWindow *= 2
+	} else {
+		fetchWindow /= 2
+	}
+	if fetchWindow < 1 {
+		fetchWindow = 1
+	} else if fetchWindow > maxBatchClocks {
+		fetchWindow = maxBatchClocks
+	}
+	statusMessage = fmt.Sprintf("fetching %d lamport clock(s) at once", fetchWindow)
+}
 
-	// Handle any errors that occurred in the response body reading
+// loadTransactions loads the transactions for the given lamport clock into the transactions map, unless already
+// loaded. The clocks after it in the fetch window are loaded along with it. When fetching fails, the error is kept in
+// fetchErrors and not retried until the user asks for it. A lamport clock that's present in the map is loaded (its
+// slice is never nil, but may be empty), one that's absent isn't loaded (yet).
+func loadTransactions(clock int) {
+	if _, ok := transactions[clock]; ok {
+		return
+	}
+	if _, failed := fetchErrors[clock]; failed {
+		return
+	}
+	clocks := []int{clock}
+	for next := clock + 1; next < clock+fetchWindow; next++ {
+		_, loaded := transactions[next]
+		_, failed := fetchErrors[next]
+		if !loaded && !failed {
+			clocks = append(clocks, next)
+		}
+	}
+	result, err := client.TransactionsAt(viewerContext, clocks)
 	if err != nil {
-		log.Panicf("failed to read response body: %v", err)
+		fetchErrors[clock] = err
+		lastFailedClock = clock
+		return
+	}
+	for loadedClock, loaded := range result {
+		if loaded == nil {
+			loaded = []string{}
+		}
+		sortTransactions(loaded, transactionOrder)
+		transactions[loadedClock] = loaded
 	}
+}
 
-	// Parse the JSON from the body
-	var transactions []string
-	json.Unmarshal(body, &transactions)
+// sortTransactions sorts the transactions of a lamport clock in the given order:
+//   - node: the order the node returned them in (no sorting)
+//   - hash: by transaction hash
+//   - time: by signing time, transactions that can't be parsed go last
+//
+// Sorting by hash or time makes sub index positions stable across runs and nodes.
+func sortTransactions(transactions []string, order string) {
+	switch order {
+	case "hash":
+		sort.SliceStable(transactions, func(i, j int) bool {
+			return hash.SHA256Sum([]byte(transactions[i])).Compare(hash.SHA256Sum([]byte(transactions[j]))) < 0
+		})
+	case "time":
+		signingTimes := make(map[string]time.Time, len(transactions))
+		for _, transaction := range transactions {
+			if tx, err := dag.ParseTransaction([]byte(transaction)); err == nil {
+				signingTimes[transaction] = tx.SigningTime()
+			}
+		}
+		sort.SliceStable(transactions, func(i, j int) bool {
+			left, leftOK := signingTimes[transactions[i]]
+			right, rightOK := signingTimes[transactions[j]]
+			if leftOK != rightOK {
+				return leftOK
+			}
+			return left.Before(right)
+		})
+	}
+}
+
+// retryFailedFetch forgets the failed fetch for the current lamport clock (or, if that didn't fail, the last failed
+// one), so it's attempted again on the next render. A failed payload verification of the current transaction is
+// retried as well, and the head of the DAG is queried again.
+func retryFailedFetch() {
+	if _, failed := fetchErrors[dagLamportClock]; failed {
+		delete(fetchErrors, dagLamportClock)
+	} else if lastFailedClock >= 0 {
+		delete(fetchErrors, lastFailedClock)
+	}
+	lastFailedClock = -1
+	resolutions = make(map[string]resolution)
+	payloadErrors = make(map[hash.SHA256Hash]error)
+	// New transactions may have arrived that refer to what used to be a head, so query it again
+	heads = make(map[hash.SHA256Hash]bool)
+	headClock = -1
+	headQueryFailed = false
+	if dagSubIndex < len(transactions[dagLamportClock]) {
+		if tx, err := dag.ParseTransaction([]byte(transactions[dagLamportClock][dagSubIndex])); err == nil {
+			delete(payloadVerifications, tx.Ref())
+		}
+	}
+}
 
-	// Return the transactions within the matching lambert clock range
-	return transactions
+// clearCaches forgets everything fetched from the node and what was derived from it, so all of it is fetched again
+// (e.g. after the node was restored from a backup)
+func clearCaches() {
+	client.ClearCache()
+	transactions = make(transactionMap)
+	decodedHeaders = make(map[string]decodedHeader)
+	fetchErrors = make(map[int]error)
+	lastFailedClock = -1
+	payloadVerifications = make(map[hash.SHA256Hash]string)
+	payloadErrors = make(map[hash.SHA256Hash]error)
+	resolutions = make(map[string]resolution)
+	heads = make(map[hash.SHA256Hash]bool)
+	headTransactions = nil
+	headQueryFailed = false
+	headClock = -1
 }
 
 func init() {
 	transactions = make(transactionMap)
+	fetchErrors = make(map[int]error)
+	payloadVerifications = make(map[hash.SHA256Hash]string)
+	decodedHeaders = make(map[string]decodedHeader)
+	payloadErrors = make(map[hash.SHA256Hash]error)
+	heads = make(map[hash.SHA256Hash]bool)
 }
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"github.com/nuts-foundation/nuts-node/network/dag"
+	"testing"
+)
+
+func TestPayloadText(t *testing.T) {
+	testCases := []struct {
+		name     string
+		payload  string
+		expected string
+	}{
+		{"object", `{"id":"did:nuts:A","@context":"https://www.w3.org/ns/did/v1"}`, "{\n    \"@context\": \"https://www.w3.org/ns/did/v1\",\n    \"id\": \"did:nuts:A\"\n}"},
+		{"array", `[{"id":"did:nuts:A"}]`, "[\n    {\n        \"id\": \"did:nuts:A\"\n    }\n]"},
+		{"scalar", `"did:nuts:A"`, `"did:nuts:A"`},
+		{"null", `null`, `null`},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			rawTransaction := withCachedPayload(t, testCase.payload)
+			original := canonicalDIDDocuments
+			canonicalDIDDocuments = true
+			defer func() {
+				canonicalDIDDocuments = original
+			}()
+
+			if actual := payloadText(rawTransaction); actual != testCase.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestCachedPayloadVerification(t *testing.T) {
+	// The payload of the test transaction is its number (1) as 4 bytes, big-endian
+	testCases := []struct {
+		name     string
+		payload  string
+		expected string
+	}{
+		{"verified", "\x00\x00\x00\x01", "[✔ hash verified](fg:green)"},
+		{"mismatch", "{}", "[✘ hash mismatch](fg:red)"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			tx, err := dag.ParseTransaction([]byte(withCachedPayload(t, testCase.payload)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if actual := cachedPayloadVerification(tx); actual != testCase.expected {
+				t.Errorf("expected %s, got %s", testCase.expected, actual)
+			}
+		})
+	}
+	t.Run("not fetched", func(t *testing.T) {
+		tx, err := dag.ParseTransaction([]byte(withCachedPayload(t, "{}")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Fetching the payload would fail, since the client doesn't have a node URL
+		client.ClearCache()
+
+		if actual := cachedPayloadVerification(tx); actual != "[unverified](fg:white)" {
+			t.Errorf("expected the payload not to be fetched, got %s", actual)
+		}
+		if _, ok := payloadVerifications[tx.Ref()]; ok {
+			t.Error("expected the unverified payload not to be cached as verification")
+		}
+	})
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxEmptyClockProbe limits how many lamport clocks are probed when skipping empty clocks, so a huge gap doesn't hang the UI
+const maxEmptyClockProbe = 100
+
+// transactionSource tells navigation how many transactions there are at a lamport clock. Navigation doesn't need to
+// know anything else about the DAG, which keeps it independent of the node and the terminal.
+type transactionSource interface {
+	TransactionCount(clock int) int
+}
+
+// viewerTransactions is the transactionSource of the viewer, which fetches the transactions from the node as needed
+type viewerTransactions struct{}
+
+func (viewerTransactions) TransactionCount(clock int) int {
+	loadTransactions(clock)
+	return len(transactions[clock])
+}
+
+// position identifies a transaction in the DAG by its lamport clock and sub index within that clock
+type position struct {
+	clock    int
+	subIndex int
+}
+
+// navigator moves through the DAG of a transactionSource
+type navigator struct {
+	source          transactionSource
+	maxClock        int
+	skipEmptyClocks bool
+}
+
+// newNavigator returns the navigator for the viewer's current settings
+func newNavigator() navigator {
+	return navigator{
+		source:          viewerTransactions{},
+		maxClock:        dagMaxLamportClock,
+		skipEmptyClocks: skipEmptyClocks,
+	}
+}
+
+// step moves one transaction left (direction < 0) or right (direction > 0) of the given position, crossing into the
+// neighbouring lamport clock at the ends of a clock. When skipping empty clocks and no clock with transactions is found
+// within maxEmptyClockProbe clocks, it stays at the given position and returns an error saying so.
+func (n navigator) step(from position, direction int) (position, error) {
+	to := from
+	// Handle the user navigating left
+	if direction < 0 {
+		// Decrement the sub index within a particular lamport clock if possible
+		if from.subIndex > 0 {
+			to.subIndex--
+
+			// Otherwise decrement the lamport clock if possible
+		} else if from.clock > 0 {
+			clock, err := n.nextClock(from.clock, -1)
+			if err != nil {
+				return from, err
+			}
+			to.clock = clock
+
+			// Select the "rightmost" transaction within the new lamport clock
+			to.subIndex = 0
+			if count := n.source.TransactionCount(to.clock); count > 0 {
+				to.subIndex = count - 1
+			}
+		}
+
+		// Handle the user navigating right
+	} else if direction > 0 {
+		// Increment the sub index within a particular lamport clock if possible
+		if from.subIndex+1 < n.source.TransactionCount(from.clock) {
+			to.subIndex++
+
+			// Otherwise increment the lamport clock if possible, resetting the sub index
+		} else if from.clock < n.maxClock {
+			clock, err := n.nextClock(from.clock, 1)
+			if err != nil {
+				return from, err
+			}
+			to.clock = clock
+
+			// Reset the sub index to select the "leftmost" transaction within the
+			// new lamport clock
+			to.subIndex = 0
+		}
+	}
+	return to, nil
+}
+
+// nextClock returns the lamport clock next to the given clock in the given direction (-1 or 1). When skipping
+// empty clocks it keeps moving until a clock with transactions is found. If the bounds are reached or
+// maxEmptyClockProbe clocks have been probed without finding one, it returns an error.
+func (n navigator) nextClock(clock int, direction int) (int, error) {
+	next := clock + direction
+	if !n.skipEmptyClocks {
+		return next, nil
+	}
+	for probed := 0; probed < maxEmptyClockProbe && next >= 0 && next <= n.maxClock; probed++ {
+		if n.source.TransactionCount(next) > 0 {
+			return next, nil
+		}
+		next += direction
+	}
+	if next < 0 || next > n.maxClock {
+		return clock, fmt.Errorf("no lamport clock with transactions %s clock %d", directionName(direction), clock)
+	}
+	return clock, fmt.Errorf("no lamport clock with transactions within %d clocks %s clock %d", maxEmptyClockProbe, directionName(direction), clock)
+}
+
+// directionName describes the given direction for messages shown to the user
+func directionName(direction int) string {
+	if direction < 0 {
+		return "before"
+	}
+	return "after"
+}
+
+// jump returns the position the user entered in the readline: an absolute lamport clock (N) or position (N.M), or a
+// lamport clock relative to the given position (+N or -N), which is clamped to the bounds of the DAG
+func (n navigator) jump(from position, input string) (position, error) {
+	if !strings.HasPrefix(input, "+") && !strings.HasPrefix(input, "-") {
+		clock, subIndex, err := parsePosition(input)
+		if err != nil {
+			return from, fmt.Errorf("invalid transaction number: %s", input)
+		}
+		if subIndex > 0 {
+			if count := n.source.TransactionCount(clock); subIndex >= count {
+				return from, fmt.Errorf("no transaction at position %d.%d (lamport clock %d has %d transactions)", clock, subIndex, clock, count)
+			}
+		}
+		return position{clock: clock, subIndex: subIndex}, nil
+	}
+	clock, err := strconv.ParseInt(input, 10, 32)
+	if err != nil {
+		return from, fmt.Errorf("invalid transaction number: %s", input)
+	}
+	to := position{clock: from.clock + int(clock)}
+	if to.clock < 0 {
+		to.clock = 0
+	} else if to.clock > n.maxClock {
+		to.clock = n.maxClock
+	}
+	return to, nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// countingSource is an in-memory transactionSource: the number of transactions per lamport clock, clocks that aren't
+// in the map are empty
+type countingSource map[int]int
+
+func (s countingSource) TransactionCount(clock int) int {
+	return s[clock]
+}
+
+func TestNavigatorStep(t *testing.T) {
+	// Clock 0 has 1 transaction, clock 1 has 3 (a branch), clocks 2-4 are empty and clock 5 has 2
+	source := countingSource{0: 1, 1: 3, 5: 2}
+	testCases := []struct {
+		name      string
+		skipEmpty bool
+		from      position
+		direction int
+		expected  position
+		err       string
+	}{
+		{"right within clock", false, position{1, 0}, 1, position{1, 1}, ""},
+		{"left within clock", false, position{1, 2}, -1, position{1, 1}, ""},
+		{"right across clock", false, position{0, 0}, 1, position{1, 0}, ""},
+		{"right from last branch", false, position{1, 2}, 1, position{2, 0}, ""},
+		{"left across clock selects last branch", false, position{2, 0}, -1, position{1, 2}, ""},
+		{"left into empty clock", false, position{5, 0}, -1, position{4, 0}, ""},
+		{"left at start of DAG", false, position{0, 0}, -1, position{0, 0}, ""},
+		{"right at end of DAG", false, position{10, 0}, 1, position{10, 0}, ""},
+		{"right skipping empty clocks", true, position{1, 2}, 1, position{5, 0}, ""},
+		{"left skipping empty clocks selects last branch", true, position{5, 0}, -1, position{1, 2}, ""},
+		{"right skipping empty clocks at end of DAG", true, position{5, 1}, 1, position{5, 1}, "no lamport clock with transactions after clock 5"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			n := navigator{source: source, maxClock: 10, skipEmptyClocks: testCase.skipEmpty}
+			actual, err := n.step(testCase.from, testCase.direction)
+			if testCase.err != "" {
+				if err == nil || err.Error() != testCase.err {
+					t.Fatalf("expected error %q, got %v", testCase.err, err)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if actual != testCase.expected {
+				t.Errorf("expected %v, got %v", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestNavigatorStep_ProbeLimit(t *testing.T) {
+	// The next populated clock is further away than maxEmptyClockProbe
+	source := countingSource{0: 1, 1: 2, maxEmptyClockProbe + 2: 1}
+	n := navigator{source: source, maxClock: 1000, skipEmptyClocks: true}
+	from := position{1, 1}
+
+	to, err := n.step(from, 1)
+
+	if to != from {
+		t.Errorf("expected to stay at %v, got %v", from, to)
+	}
+	expected := "no lamport clock with transactions within 100 clocks after clock 1"
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected error %q, got %v", expected, err)
+	}
+}
+
+func TestNavigatorJump(t *testing.T) {
+	source := countingSource{0: 1, 1: 3, 5: 2}
+	testCases := []struct {
+		name     string
+		from     position
+		input    string
+		expected position
+		err      string
+	}{
+		{"absolute clock", position{1, 2}, "5", position{5, 0}, ""},
+		{"absolute position", position{0, 0}, "1.2", position{1, 2}, ""},
+		{"position past last branch", position{0, 0}, "1.3", position{0, 0}, "no transaction at position 1.3 (lamport clock 1 has 3 transactions)"},
+		{"position in empty clock", position{0, 0}, "3.1", position{0, 0}, "no transaction at position 3.1 (lamport clock 3 has 0 transactions)"},
+		{"relative forward", position{1, 2}, "+3", position{4, 0}, ""},
+		{"relative backward", position{5, 1}, "-4", position{1, 0}, ""},
+		{"relative clamped to start", position{1, 0}, "-5", position{0, 0}, ""},
+		{"relative clamped to end", position{5, 0}, "+50", position{10, 0}, ""},
+		{"invalid", position{1, 1}, "abc", position{1, 1}, "invalid transaction number: abc"},
+		{"invalid relative", position{1, 1}, "+x", position{1, 1}, "invalid transaction number: +x"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			n := navigator{source: source, maxClock: 10}
+			actual, err := n.jump(testCase.from, testCase.input)
+			if testCase.err != "" {
+				if err == nil || err.Error() != testCase.err {
+					t.Fatalf("expected error %q, got %v", testCase.err, err)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if actual != testCase.expected {
+				t.Errorf("expected %v, got %v", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestViewerTransactions_CorruptTransaction(t *testing.T) {
+	// Lamport clock 1 holds a valid and a corrupt transaction, as loaded from the node
+	corrupt := "!!!." + encodeSegment("payload-hash") + "." + encodeSegment("signature")
+	original := transactions
+	transactions = transactionMap{
+		0: {testTransaction(didDocumentHeader)},
+		1: {corrupt, testTransaction(credentialHeader)},
+		2: {testTransaction(didDocumentHeader)},
+	}
+	decodedHeaders = make(map[string]decodedHeader)
+	t.Cleanup(func() {
+		transactions = original
+		decodedHeaders = make(map[string]decodedHeader)
+	})
+
+	t.Run("decoding", func(t *testing.T) {
+		if err := decodeTransaction(corrupt).err; err == nil {
+			t.Error("expected the corrupt transaction to fail to decode")
+		}
+		valid := decodeTransaction(transactions[1][1])
+		if valid.err != nil {
+			t.Fatalf("expected the valid transaction to decode, got %v", valid.err)
+		}
+		if !strings.Contains(valid.indented, "application/vc+json") {
+			t.Errorf("expected the decoded header of the valid transaction, got %s", valid.indented)
+		}
+		if err := decodeTransaction(corrupt).err; err == nil {
+			t.Error("expected the error of the corrupt transaction to be cached")
+		}
+	})
+	t.Run("navigation", func(t *testing.T) {
+		n := navigator{source: viewerTransactions{}, maxClock: 2}
+		path := []position{{0, 0}, {1, 0}, {1, 1}, {2, 0}}
+		for i := 1; i < len(path); i++ {
+			if actual, err := n.step(path[i-1], 1); err != nil || actual != path[i] {
+				t.Errorf("expected to move right from %v to %v, got %v (%v)", path[i-1], path[i], actual, err)
+			}
+			if actual, err := n.step(path[i], -1); err != nil || actual != path[i-1] {
+				t.Errorf("expected to move left from %v to %v, got %v (%v)", path[i], path[i-1], actual, err)
+			}
+		}
+		if len(transactions[1]) != 2 {
+			t.Errorf("expected both transactions of lamport clock 1 to stay loaded, got %d", len(transactions[1]))
+		}
+	})
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/nuts-foundation/go-did/did"
+	"github.com/nuts-foundation/nuts-node/network/dag"
+	vdrAPI "github.com/nuts-foundation/nuts-node/vdr/api/v1"
+	"log"
+
+	ui "github.com/gizak/termui/v3"
+	"github.com/gizak/termui/v3/widgets"
+)
+
+// runResolve implements the resolve subcommand, which prints the current state of a DID document (and its metadata)
+// as resolved by the VDR of the node.
+func runResolve(args []string) {
+	flags := flag.NewFlagSet("resolve", flag.ExitOnError)
+	flags.StringVar(&nodeURL, "node", nodeURL, "base URL of the nuts node")
+	registerHTTPFlags(flags)
+	_ = flags.Parse(args)
+	if flags.NArg() != 1 {
+		log.Fatal("resolve requires a DID as argument")
+	}
+	var err error
+	if nodeURL, err = setupNodeAccess(nodeURL); err != nil {
+		log.Fatal(err)
+	}
+
+	result, err := fetchDIDResolution(context.Background(), NewClient(nodeURL), flags.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	data, err := json.MarshalIndent(result, "", "    ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(string(data))
+}
+
+var showResolved bool = false
+
+// resolution is the outcome of resolving the current state of a DID through the VDR
+type resolution struct {
+	result *vdrAPI.DIDResolutionResult
+	err    error
+}
+
+// resolutions caches the resolved DIDs, keyed by DID. It's cleared when retrying failed fetches.
+var resolutions = make(map[string]resolution)
+
+// resolveDID returns the current state of the given DID, resolving it if it isn't cached yet
+func resolveDID(id string) resolution {
+	if cached, ok := resolutions[id]; ok {
+		return cached
+	}
+	result, err := fetchDIDResolution(viewerContext, client, id)
+	resolutions[id] = resolution{result: result, err: err}
+	return resolutions[id]
+}
+
+// fetchDIDResolution resolves the current state of the given DID through the VDR API of the node of the given client
+func fetchDIDResolution(ctx context.Context, client *Client, id string) (*vdrAPI.DIDResolutionResult, error) {
+	vdrClient, err := vdrAPI.NewClient(client.URL, vdrAPI.WithHTTPClient(client.HTTPClient))
+	if err != nil {
+		return nil, err
+	}
+	httpResponse, err := vdrClient.GetDID(ctx, id, &vdrAPI.GetDIDParams{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get DID document: %w", err)
+	}
+	response, err := vdrAPI.ParseGetDIDResponse(httpResponse)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse GetDID response: %w", err)
+	}
+	if response.JSON200 == nil {
+		return nil, fmt.Errorf("no DID document found (status=%d)", response.StatusCode())
+	}
+	return response.JSON200, nil
+}
+
+// renderResolved renders the DID document in the given transaction side-by-side with the current (resolved) state of
+// that DID, indicating whether the transaction is the latest version of the DID document.
+func renderResolved(rawTransaction string, width int, height int) {
+	tx, err := dag.ParseTransaction([]byte(rawTransaction))
+	if err != nil || tx.PayloadType() != didDocumentType {
+		p := widgets.NewParagraph()
+		p.Title = "| Resolved |"
+		p.Text = "This transaction doesn't contain a DID document (press d to close)"
+		p.SetRect(0, 0, width, height-1)
+		ui.Render(p)
+		return
+	}
+	historical := widgets.NewParagraph()
+	historical.Title = "| DID document in this transaction |"
+	historical.SetRect(0, 0, width/2, height-1)
+	current := widgets.NewParagraph()
+	current.SetRect(width/2, 0, width, height-1)
+
+	payload, err := loadPayload(tx.Ref())
+	if err != nil {
+		historical.Text = err.Error()
+		ui.Render(historical, current)
+		return
+	}
+	historical.Text = indentOrRaw(payload)
+	if kind := jsonKind(payload); kind != "object" {
+		historical.Text = fmt.Sprintf("the payload is not a JSON object (but %s), so it can't be a DID document\n\n%s", describeJSONKind(kind), historical.Text)
+		ui.Render(historical, current)
+		return
+	}
+	document := did.Document{}
+	if err := json.Unmarshal(payload, &document); err != nil {
+		historical.Text = fmt.Sprintf("invalid DID document: %v", err)
+		ui.Render(historical, current)
+		return
+	}
+
+	resolved := resolveDID(document.ID.String())
+	if resolved.err != nil {
+		current.Title = "| Resolved |"
+		current.Text = resolved.err.Error()
+		ui.Render(historical, current)
+		return
+	}
+	metadata := resolved.result.DocumentMetadata
+	latest := false
+	for _, sourceTransaction := range metadata.SourceTransactions {
+		if sourceTransaction.Equals(tx.Ref()) {
+			latest = true
+		}
+	}
+	switch {
+	case metadata.Deactivated:
+		current.Title = "| Resolved: deactivated |"
+		current.BorderStyle.Fg = ui.ColorRed
+	case latest:
+		current.Title = "| Resolved: this is the latest version |"
+		current.BorderStyle.Fg = ui.ColorGreen
+	default:
+		current.Title = "| Resolved: superseded by a later version |"
+		current.BorderStyle.Fg = ui.ColorYellow
+	}
+	resolvedDocument, _ := json.Marshal(resolved.result.Document)
+	current.Text = indentOrRaw(resolvedDocument)
+	ui.Render(historical, current)
+}
+
+// indentOrRaw returns the given data as indented JSON, or as-is if it isn't valid JSON
+func indentOrRaw(data []byte) string {
+	if indented, err := indentJSON(data); err == nil {
+		return indented
+	}
+	return string(data)
+}
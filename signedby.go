@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/nuts-foundation/nuts-node/crypto/hash"
+	"log"
+	"os"
+	"os/signal"
+)
+
+// runSignedBy implements the signed-by subcommand, which lists the transactions in a range of lamport clocks that were
+// signed by the given key, as position (N.M, which can be passed to get or jumped to in the viewer) and hash.
+func runSignedBy(args []string) {
+	flags := flag.NewFlagSet("signed-by", flag.ExitOnError)
+	flags.StringVar(&nodeURL, "node", nodeURL, "base URL of the nuts node")
+	registerHTTPFlags(flags)
+	registerConcurrencyFlag(flags)
+	start := flags.Int("start", 0, "first lamport clock (inclusive)")
+	end := flags.Int("end", -1, "last lamport clock (exclusive), defaults to the end of the DAG")
+	flags.StringVar(&transactionOrder, "sort", "node", "order of the transactions within a lamport clock: node, hash or time")
+	_ = flags.Parse(args)
+	if flags.NArg() != 1 {
+		log.Fatal("signed-by requires a key ID (kid) as argument")
+	}
+	if !validTransactionOrder(transactionOrder) {
+		log.Fatalf("invalid sort order: %s", transactionOrder)
+	}
+	var err error
+	if nodeURL, err = setupNodeAccess(nodeURL); err != nil {
+		log.Fatal(err)
+	}
+
+	// Allow interrupting long scans
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	client := NewClient(nodeURL)
+	if *end < 0 {
+		head, err := client.Head(ctx)
+		if err != nil {
+			log.Fatalf("failed to determine the end of the DAG (use -end): %v", err)
+		}
+		*end = head.HighestClock + 1
+	}
+	err = scanSignedBy(ctx, client, flags.Arg(0), *start, *end, func(clock int, subIndex int, rawTransaction string) {
+		fmt.Printf("%d.%d %s\n", clock, subIndex, hash.SHA256Sum([]byte(rawTransaction)))
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// scanSignedBy calls found for every transaction in the range of lamport clocks [start, end) signed by the given key.
+// The key ID is matched against the kid of the header, or that of the embedded key (JWK) for transactions that create
+// a DID document.
+func scanSignedBy(ctx context.Context, client *Client, kid string, start int, end int, found func(clock int, subIndex int, rawTransaction string)) error {
+	return client.forEachClockBatch(ctx, start, end, func(clock int, transactions []string) {
+		sortTransactions(transactions, transactionOrder)
+		for subIndex, rawTransaction := range transactions {
+			if signingKeyID(rawTransaction) == kid {
+				found(clock, subIndex, rawTransaction)
+			}
+		}
+	})
+}
+
+// signingKeyID returns the ID of the key that signed the given transaction, read from its header. It's empty if the
+// header can't be decoded.
+func signingKeyID(rawTransaction string) string {
+	rawHeader, err := decodeRawHeader(rawTransaction)
+	if err != nil {
+		return ""
+	}
+	var header struct {
+		KeyID string `json:"kid"`
+		JWK   struct {
+			KeyID string `json:"kid"`
+		} `json:"jwk"`
+	}
+	if json.Unmarshal(rawHeader, &header) != nil {
+		return ""
+	}
+	if header.KeyID != "" {
+		return header.KeyID
+	}
+	return header.JWK.KeyID
+}
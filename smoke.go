@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+)
+
+// runSmokeTest implements -smoke: it fetches and decodes the first transaction (at lamport clock 0) and prints it,
+// without starting the terminal UI. It exits 0 if that worked and 1 otherwise, which makes it a quick end-to-end check
+// that the node is reachable and its transactions can be decoded.
+func runSmokeTest() {
+	ctx := context.Background()
+	client := NewClient(nodeURL)
+	transactions, err := client.TransactionsInRange(ctx, 0, 1)
+	if err != nil {
+		log.Fatalf("smoke test failed: failed to get transactions at lamport clock 0: %v", err)
+	}
+	if len(transactions) == 0 {
+		log.Fatal("smoke test failed: there are no transactions at lamport clock 0")
+	}
+	sortTransactions(transactions, transactionOrder)
+	output, err := formatTransaction(ctx, client, transactions[0], "all")
+	if err != nil {
+		log.Fatalf("smoke test failed: %v", err)
+	}
+	fmt.Println(output)
+	os.Exit(0)
+}
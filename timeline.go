@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"github.com/nuts-foundation/data-viewer/analyzers"
+	"github.com/nuts-foundation/go-did/did"
+	"github.com/nuts-foundation/nuts-node/network/dag"
+	"strings"
+
+	ui "github.com/gizak/termui/v3"
+	"github.com/gizak/termui/v3/widgets"
+)
+
+var showTimeline bool = false
+
+// timelineEntry is a version of a DID document in the timeline
+type timelineEntry struct {
+	tx          dag.Transaction
+	notes       []string
+	controllers []string
+}
+
+// timeline holds the versions of the DID document the timeline was opened for, oldest first. It's built when the
+// timeline is opened, and extended while timelineScan finds later versions.
+var timeline []timelineEntry
+
+// timelineCursor is the index of the selected version in the timeline
+var timelineCursor int
+
+// timelineScan scans for the versions that follow the transaction the timeline was opened for, while that's in
+// progress. The event loop continues it one batch of lamport clocks at a time (see continueTimelineScan), so the viewer
+// stays responsive and the scan can be stopped.
+var timelineScan *versionScan
+
+// toggleTimeline opens the timeline of the DID document in the current transaction, or closes it when it's open. The
+// versions before it are found right away, the versions after it by timelineScan.
+func toggleTimeline() {
+	if showTimeline {
+		showTimeline = false
+		timelineScan = nil
+		return
+	}
+	tx, document := currentDIDDocument()
+	if document == nil {
+		return
+	}
+	versions, err := previousVersions(tx, document)
+	if err != nil {
+		statusMessage = err.Error()
+		return
+	}
+	head, err := client.Head(viewerContext)
+	if err != nil {
+		statusMessage = fmt.Sprintf("failed to determine the head of the DAG: %v", err)
+		return
+	}
+	timeline = nil
+	for _, version := range versions {
+		addTimelineEntry(version)
+	}
+	timelineCursor = len(timeline) - 1
+	timelineScan = newVersionScan(tx, document.ID.String(), head.HighestClock)
+	if timelineScan.done() {
+		timelineScan = nil
+	}
+	showTimeline = true
+}
+
+// addTimelineEntry adds the given version to the end of the timeline
+func addTimelineEntry(version dag.Transaction) {
+	entry := timelineEntry{tx: version, controllers: []string{"unknown"}}
+	if versionDocument := transactionDIDDocument(version); versionDocument != nil {
+		entry.notes = analyzers.Classify(version, versionDocument)
+		entry.controllers = nil
+		for _, controller := range versionDocument.Controller {
+			entry.controllers = append(entry.controllers, controller.String())
+		}
+		if len(entry.controllers) == 0 {
+			entry.controllers = []string{"none"}
+		}
+	}
+	timeline = append(timeline, entry)
+}
+
+// continueTimelineScan scans the next batch of lamport clocks for versions to add to the timeline. It's called by the
+// event loop while timelineScan is in progress.
+func continueTimelineScan() {
+	found := len(timelineScan.versions)
+	if err := timelineScan.step(); err != nil {
+		statusMessage = fmt.Sprintf("stopped looking for later versions: %v", err)
+		timelineScan = nil
+		return
+	}
+	for _, version := range timelineScan.versions[found:] {
+		addTimelineEntry(version)
+	}
+	if timelineScan.done() {
+		timelineScan = nil
+	}
+}
+
+// stopTimelineScan stops looking for later versions, keeping the ones found so far in the timeline
+func stopTimelineScan() {
+	timelineScan = nil
+	statusMessage = "stopped looking for later versions, the timeline may be incomplete"
+}
+
+// previousVersions returns the given version of the DID document (contained in the given transaction) and the
+// versions before it, oldest first. They're found by walking its parents.
+func previousVersions(tx dag.Transaction, document *did.Document) ([]dag.Transaction, error) {
+	versions := []dag.Transaction{tx}
+	for version := tx; ; {
+		previous, err := previousVersion(version, document)
+		if err != nil {
+			return nil, err
+		}
+		if previous == nil {
+			return versions, nil
+		}
+		versions = append([]dag.Transaction{previous}, versions...)
+		version = previous
+	}
+}
+
+// moveTimelineCursor selects the previous (direction < 0) or next version in the timeline
+func moveTimelineCursor(direction int) {
+	timelineCursor += direction
+	if timelineCursor < 0 {
+		timelineCursor = 0
+	} else if timelineCursor >= len(timeline) {
+		timelineCursor = len(timeline) - 1
+	}
+}
+
+// goToTimelineSelection closes the timeline and moves to the selected version
+func goToTimelineSelection() {
+	showTimeline = false
+	if timelineCursor < len(timeline) {
+		goToTransaction(timeline[timelineCursor].tx)
+	}
+}
+
+// renderTimeline renders an overlay listing the versions of the DID document, with the selected one highlighted
+func renderTimeline(width int, height int) {
+	l := widgets.NewList()
+	l.Title = fmt.Sprintf("| Timeline (%d versions, Enter to go to the selected one) |", len(timeline))
+	if timelineScan != nil {
+		l.Title = fmt.Sprintf("| Timeline (%d versions, looking for more at lamport clock %d of %d, Escape stops) |",
+			len(timeline), timelineScan.start, timelineScan.highestClock)
+	}
+	l.Rows = []string{fmt.Sprintf("  %-8s %-16s %-20s %s", "Clock", "Transaction", "State", "Controllers")}
+	for _, entry := range timeline {
+		ref := displayHash(entry.tx.Ref())
+		if len(ref) > 16 {
+			ref = ref[:13] + "..."
+		}
+		l.Rows = append(l.Rows, fmt.Sprintf("  %-8d %-16s %-20s %s", entry.tx.Clock(), ref,
+			strings.Join(entry.notes, ", "), strings.Join(entry.controllers, ", ")))
+	}
+	// The first row is the header
+	l.SelectedRow = timelineCursor + 1
+	l.SelectedRowStyle = ui.NewStyle(ui.ColorBlack, ui.ColorWhite)
+	l.SetRect(0, 0, width-1, height-1)
+	ui.Render(l)
+}
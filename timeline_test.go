@@ -0,0 +1,94 @@
+package main
+
+import (
+	"github.com/nuts-foundation/nuts-node/crypto/hash"
+	"testing"
+)
+
+// openTimeline opens the timeline of the given version of a DID document, as if the user pressed t while viewing it
+func openTimeline(t *testing.T, d *testViewerDAG, index int) {
+	tx := d.transactions[index]
+	original := transactions
+	transactions = transactionMap{int(tx.Clock()): {string(tx.Data())}}
+	dagLamportClock, dagSubIndex = int(tx.Clock()), 0
+	t.Cleanup(func() {
+		transactions = original
+		dagLamportClock, dagSubIndex = 0, 0
+		showTimeline, timeline, timelineScan, statusMessage = false, nil, nil, ""
+	})
+
+	toggleTimeline()
+
+	if !showTimeline {
+		t.Fatalf("expected the timeline to open, got status %q", statusMessage)
+	}
+}
+
+// timelineRefs returns the transactions in the timeline
+func timelineRefs() []hash.SHA256Hash {
+	var result []hash.SHA256Hash
+	for _, entry := range timeline {
+		result = append(result, entry.tx.Ref())
+	}
+	return result
+}
+
+func TestTimeline(t *testing.T) {
+	// A is updated 3 times, with an unrelated transaction in between
+	newDAG := func(t *testing.T) *testViewerDAG {
+		d := withDAG(t)
+		created := d.add("did:nuts:A")
+		first := d.add("did:nuts:A", created)
+		unrelated := d.add("did:nuts:B", first)
+		second := d.add("did:nuts:A", unrelated)
+		d.add("did:nuts:A", second)
+		return d
+	}
+	t.Run("all versions in a single scan", func(t *testing.T) {
+		d := newDAG(t)
+
+		openTimeline(t, d, 1)
+		for timelineScan != nil {
+			continueTimelineScan()
+		}
+
+		expected := []hash.SHA256Hash{d.transactions[0].Ref(), d.transactions[1].Ref(), d.transactions[3].Ref(), d.transactions[4].Ref()}
+		actual := timelineRefs()
+		if len(actual) != len(expected) {
+			t.Fatalf("expected %d versions, got %d", len(expected), len(actual))
+		}
+		for i := range expected {
+			if !actual[i].Equals(expected[i]) {
+				t.Errorf("expected version %d to be %s, got %s", i, expected[i], actual[i])
+			}
+		}
+		if timelineCursor != 1 {
+			t.Errorf("expected the version the timeline was opened for to be selected, got %d", timelineCursor)
+		}
+		if d.scannedRanges != 1 {
+			t.Errorf("expected the DAG to be scanned once, got %d scans", d.scannedRanges)
+		}
+	})
+	t.Run("stopped", func(t *testing.T) {
+		d := newDAG(t)
+
+		openTimeline(t, d, 1)
+		stopTimelineScan()
+
+		if len(timeline) != 2 || d.scannedRanges != 0 {
+			t.Errorf("expected only the versions before it, got %d versions after %d scans", len(timeline), d.scannedRanges)
+		}
+	})
+	t.Run("latest version", func(t *testing.T) {
+		d := newDAG(t)
+
+		openTimeline(t, d, 4)
+
+		if timelineScan != nil {
+			t.Error("expected no scan after the head of the DAG")
+		}
+		if len(timeline) != 4 {
+			t.Errorf("expected 4 versions, got %d", len(timeline))
+		}
+	})
+}
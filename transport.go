@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Connection tuning of the HTTP transport used to talk to the node. The defaults suit a single node: a few connections
+// that are reused for as long as the tool runs, without keeping them open forever when it's idle.
+var idleConnTimeout = 90 * time.Second
+var maxIdleConns = 4
+var keepAlive = 30 * time.Second
+
+// clientCertFile and clientKeyFile configure the TLS client certificate presented to nodes that require mutual TLS
+var clientCertFile string
+var clientKeyFile string
+
+// clientCertificate is the TLS client certificate loaded from clientCertFile and clientKeyFile, if configured
+var clientCertificate *tls.Certificate
+
+// pinnedFingerprint is the SHA-256 fingerprint the certificate of the node must have, if configured. It's checked on
+// top of the regular verification of the certificate, so a compromised CA can't impersonate the node.
+var pinnedFingerprint []byte
+
+// apiKeyParam is the query parameter (name and value) carrying the API key, for gateways in front of the node that
+// expect it there instead of in a header
+var apiKeyParam url.Values
+
+// sharedTransport is the HTTP transport shared by all clients, created on first use (after the flags are parsed)
+var sharedTransport *http.Transport
+
+// registerHTTPFlags registers the flags that configure how the node is talked to on the given flag set
+func registerHTTPFlags(flags *flag.FlagSet) {
+	flags.StringVar(&dumpDir, "dump-dir", "", "write the raw response bodies of the node to this directory")
+	flags.DurationVar(&idleConnTimeout, "idle-timeout", idleConnTimeout, "close connections to the node after being idle this long (0 means never)")
+	flags.IntVar(&maxIdleConns, "max-idle-conns", maxIdleConns, "maximum number of idle connections to the node kept for reuse")
+	flags.DurationVar(&keepAlive, "keep-alive", keepAlive, "interval of TCP keep-alive probes on connections to the node (negative disables them)")
+	flags.Func("api-prefix", "base path of the network API of the node (default "+apiPrefix+"), a path in front of the default is where the node is mounted, e.g. behind a gateway", func(value string) error {
+		var err error
+		apiMountPath, apiPrefix, err = parseAPIPrefix(value)
+		return err
+	})
+	flags.StringVar(&clientCertFile, "client-cert", "", "PEM file containing the TLS client certificate, for nodes that require mutual TLS")
+	flags.StringVar(&clientKeyFile, "client-key", "", "PEM file containing the private key of the TLS client certificate")
+	flags.Func("apikey-param", "query parameter (name=value) carrying an API key, added to every request to the node", func(value string) error {
+		name, key, ok := strings.Cut(value, "=")
+		if !ok || name == "" {
+			return errors.New("expected name=value")
+		}
+		apiKeyParam = url.Values{name: []string{key}}
+		return nil
+	})
+	flags.Func("pin", "SHA-256 fingerprint (hex) the TLS certificate of the node must have", func(value string) error {
+		fingerprint, err := parseFingerprint(value)
+		pinnedFingerprint = fingerprint
+		return err
+	})
+}
+
+// httpFlags returns the flags set by registerHTTPFlags that are needed to reach the node the same way, as shell
+// arguments. The value of the API key is never included: -apikey-param gets a placeholder to fill in.
+func httpFlags() []string {
+	var flags []string
+	if apiPrefix != defaultAPIPrefix {
+		flags = append(flags, "-api-prefix "+shellQuote(apiPrefix))
+	}
+	if clientCertFile != "" {
+		flags = append(flags, "-client-cert "+shellQuote(clientCertFile))
+	}
+	if clientKeyFile != "" {
+		flags = append(flags, "-client-key "+shellQuote(clientKeyFile))
+	}
+	if pinnedFingerprint != nil {
+		flags = append(flags, fmt.Sprintf("-pin %x", pinnedFingerprint))
+	}
+	for name := range apiKeyParam {
+		flags = append(flags, shellQuote("-apikey-param="+name+"=")+"\"$API_KEY\"")
+	}
+	return flags
+}
+
+// shellQuote quotes the given value as a single argument for a POSIX shell
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// parseFingerprint parses a SHA-256 certificate fingerprint in hex, optionally separated by colons (as printed by e.g.
+// openssl x509 -fingerprint -sha256)
+func parseFingerprint(value string) ([]byte, error) {
+	fingerprint, err := hex.DecodeString(strings.ReplaceAll(value, ":", ""))
+	if err != nil || len(fingerprint) != sha256.Size {
+		return nil, fmt.Errorf("not a SHA-256 fingerprint: %s", value)
+	}
+	return fingerprint, nil
+}
+
+// verifyPinnedCertificate is used as VerifyPeerCertificate of the TLS configuration when a fingerprint is pinned. It
+// rejects the connection if the certificate of the node (the first one presented) doesn't have that fingerprint.
+func verifyPinnedCertificate(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	if len(rawCerts) == 0 {
+		return errors.New("the node presented no certificate to check the pinned fingerprint against")
+	}
+	fingerprint := sha256.Sum256(rawCerts[0])
+	if !bytes.Equal(fingerprint[:], pinnedFingerprint) {
+		return fmt.Errorf("the certificate of the node doesn't match the pinned fingerprint (-pin): its fingerprint is %x", fingerprint)
+	}
+	return nil
+}
+
+// registerConcurrencyFlag registers the -concurrency flag of the subcommands that fetch many lamport clocks on the
+// given flag set
+func registerConcurrencyFlag(flags *flag.FlagSet) {
+	flags.Func("concurrency", fmt.Sprintf("maximum number of concurrent requests to the node (default %d)", fetchConcurrency), func(value string) error {
+		concurrency, err := strconv.Atoi(value)
+		if err != nil || concurrency < 1 {
+			return fmt.Errorf("must be a positive number: %s", value)
+		}
+		fetchConcurrency = concurrency
+		return nil
+	})
+}
+
+// loadClientCertificate loads the TLS client certificate if -client-cert and -client-key are set. It must be called
+// before the first HTTP client is created.
+func loadClientCertificate() error {
+	if clientCertFile == "" && clientKeyFile == "" {
+		return nil
+	}
+	if clientCertFile == "" || clientKeyFile == "" {
+		return fmt.Errorf("both -client-cert and -client-key must be set to use a TLS client certificate")
+	}
+	// Also fails if the key doesn't belong to the certificate
+	certificate, err := tls.LoadX509KeyPair(clientCertFile, clientKeyFile)
+	if err != nil {
+		return fmt.Errorf("unable to load TLS client certificate: %w", err)
+	}
+	clientCertificate = &certificate
+	return nil
+}
+
+// setupNodeAccess validates the given node URL and prepares the HTTP access to the node (see prepareHTTPAccess),
+// returning the normalized URL. Commands call it once after parsing their flags.
+func setupNodeAccess(nodeURL string) (string, error) {
+	nodeURL, err := parseNodeURL(nodeURL)
+	if err != nil {
+		return "", err
+	}
+	if err := prepareHTTPAccess(); err != nil {
+		return "", err
+	}
+	return nodeURL, nil
+}
+
+// prepareHTTPAccess applies the HTTP flags that need preparation before the first request: it creates the dump
+// directory and loads the TLS client certificate
+func prepareHTTPAccess() error {
+	if err := prepareDumpDir(); err != nil {
+		return err
+	}
+	return loadClientCertificate()
+}
+
+// newHTTPClient returns the HTTP client used to talk to the node, which dumps all responses if dumpDir is set
+func newHTTPClient() *http.Client {
+	if sharedTransport == nil {
+		sharedTransport = http.DefaultTransport.(*http.Transport).Clone()
+		sharedTransport.DialContext = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: keepAlive}).DialContext
+		sharedTransport.IdleConnTimeout = idleConnTimeout
+		sharedTransport.MaxIdleConns = maxIdleConns
+		// The node is the only host, so all idle connections may go to it (the default is only 2 per host)
+		sharedTransport.MaxIdleConnsPerHost = maxIdleConns
+		if clientCertificate != nil || pinnedFingerprint != nil {
+			sharedTransport.TLSClientConfig = &tls.Config{}
+		}
+		if clientCertificate != nil {
+			sharedTransport.TLSClientConfig.Certificates = []tls.Certificate{*clientCertificate}
+		}
+		if pinnedFingerprint != nil {
+			sharedTransport.TLSClientConfig.VerifyPeerCertificate = verifyPinnedCertificate
+		}
+	}
+	var transport http.RoundTripper = sharedTransport
+	if apiKeyParam != nil {
+		transport = apiKeyTransport{next: transport}
+	}
+	if dumpDir == "" {
+		return &http.Client{Transport: transport}
+	}
+	return &http.Client{Transport: dumpTransport{dir: dumpDir, next: transport}}
+}
+
+// apiKeyTransport is an http.RoundTripper that adds apiKeyParam to the query of each request. It sits below all other
+// round trippers (and the http.Client), so the key doesn't end up in errors, logs or dump file names.
+type apiKeyTransport struct {
+	next http.RoundTripper
+}
+
+func (t apiKeyTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	request = request.Clone(request.Context())
+	// Append the parameter instead of re-encoding the query, which leaves the other parameters (e.g. start and end)
+	// as they are
+	if request.URL.RawQuery == "" {
+		request.URL.RawQuery = apiKeyParam.Encode()
+	} else {
+		request.URL.RawQuery += "&" + apiKeyParam.Encode()
+	}
+	return t.next.RoundTrip(request)
+}
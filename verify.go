@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/nuts-foundation/go-did/did"
+	"github.com/nuts-foundation/nuts-node/crypto/hash"
+	"github.com/nuts-foundation/nuts-node/network/dag"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+)
+
+// runVerify implements the verify subcommand, which checks the signature and payload hash of every transaction in a
+// range of lamport clocks. It prints a line per transaction as it goes and a summary at the end, and exits with status
+// 1 if any transaction failed verification.
+func runVerify(args []string) {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	flags.StringVar(&nodeURL, "node", nodeURL, "base URL of the nuts node")
+	registerHTTPFlags(flags)
+	registerConcurrencyFlag(flags)
+	start := flags.Int("start", 0, "first lamport clock (inclusive)")
+	end := flags.Int("end", -1, "last lamport clock (exclusive), defaults to the end of the DAG")
+	_ = flags.Parse(args)
+	var err error
+	if nodeURL, err = setupNodeAccess(nodeURL); err != nil {
+		log.Fatal(err)
+	}
+
+	// Allow interrupting long sweeps
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	client := NewClient(nodeURL)
+	if *end < 0 {
+		head, err := client.Head(ctx)
+		if err != nil {
+			log.Fatalf("failed to determine the end of the DAG (use -end): %v", err)
+		}
+		*end = head.HighestClock + 1
+	}
+	passed, failed := 0, 0
+	err = client.forEachClockBatch(ctx, *start, *end, func(clock int, transactions []string) {
+		for subIndex, rawTransaction := range transactions {
+			ref := hash.SHA256Sum([]byte(rawTransaction))
+			if err := verifyTransaction(ctx, client, rawTransaction); err != nil {
+				failed++
+				fmt.Printf("%d.%d %s FAIL: %v\n", clock, subIndex, ref, err)
+			} else {
+				passed++
+				fmt.Printf("%d.%d %s OK\n", clock, subIndex, ref)
+			}
+		}
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("verified %d transactions: %d passed, %d failed\n", passed+failed, passed, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// verifyTransaction checks the signature of the given transaction and the hash of its payload
+func verifyTransaction(ctx context.Context, client *Client, rawTransaction string) error {
+	tx, err := dag.ParseTransaction([]byte(rawTransaction))
+	if err != nil {
+		return fmt.Errorf("failed to parse transaction: %w", err)
+	}
+	signingKey, err := resolveSigningKey(ctx, client, tx)
+	if err != nil {
+		return err
+	}
+	if _, err := jws.Verify(tx.Data(), jwa.SignatureAlgorithm(tx.SigningAlgorithm()), signingKey); err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	}
+	payload, err := client.TransactionPayload(ctx, tx.Ref())
+	if err != nil {
+		return fmt.Errorf("payload unavailable: %w", err)
+	}
+	if !hash.SHA256Sum(payload).Equals(tx.PayloadHash()) {
+		return errors.New("payload hash mismatch")
+	}
+	return nil
+}
+
+// resolveSigningKey returns the public key that signed the given transaction. That's either the key embedded in the
+// transaction or, like the node does, the key with the signing key ID in the latest version of its DID document the
+// transaction refers to.
+func resolveSigningKey(ctx context.Context, client *Client, tx dag.Transaction) (crypto.PublicKey, error) {
+	var signingKey crypto.PublicKey
+	if tx.SigningKey() != nil {
+		if err := tx.SigningKey().Raw(&signingKey); err != nil {
+			return nil, fmt.Errorf("invalid embedded signing key: %w", err)
+		}
+		return signingKey, nil
+	}
+	id, _, _ := strings.Cut(tx.SigningKeyID(), "#")
+	version, err := latestVersionBefore(ctx, client, tx.Previous(), id)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve signing key %s: %w", tx.SigningKeyID(), err)
+	}
+	if version == nil {
+		return nil, fmt.Errorf("unable to resolve signing key %s: no version of %s found in the history of the transaction", tx.SigningKeyID(), id)
+	}
+	payload, err := client.TransactionPayload(ctx, version.Ref())
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve signing key %s: %w", tx.SigningKeyID(), err)
+	}
+	document := did.Document{}
+	if err := json.Unmarshal(payload, &document); err != nil {
+		return nil, fmt.Errorf("unable to resolve signing key %s: %w", tx.SigningKeyID(), err)
+	}
+	for _, method := range document.VerificationMethod {
+		if method.ID.String() == tx.SigningKeyID() {
+			return method.PublicKey()
+		}
+	}
+	return nil, fmt.Errorf("signing key %s not found in %s (tx=%s)", tx.SigningKeyID(), id, version.Ref())
+}
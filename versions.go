@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/nuts-foundation/data-viewer/analyzers"
+	"github.com/nuts-foundation/go-did/did"
+	"github.com/nuts-foundation/nuts-node/crypto/hash"
+	"github.com/nuts-foundation/nuts-node/network/dag"
+	"sort"
+)
+
+// versionScanBatch is the number of lamport clocks fetched at once when scanning for the next version of a DID document
+const versionScanBatch = 100
+
+// jumpToVersion moves to the previous (direction < 0) or next version of the DID document in the current transaction,
+// skipping unrelated transactions in between.
+func jumpToVersion(direction int) {
+	tx, document := currentDIDDocument()
+	if document == nil {
+		return
+	}
+	var version dag.Transaction
+	var err error
+	if direction < 0 {
+		version, err = previousVersion(tx, document)
+	} else {
+		var head HeadInfo
+		if head, err = client.Head(viewerContext); err != nil {
+			err = fmt.Errorf("failed to determine the head of the DAG: %w", err)
+		} else {
+			version, err = nextVersion(tx, document.ID.String(), head.HighestClock)
+		}
+	}
+	if err != nil {
+		statusMessage = err.Error()
+	} else if version == nil && direction < 0 {
+		statusMessage = fmt.Sprintf("this is the first version of %s", document.ID)
+	} else if version == nil {
+		statusMessage = fmt.Sprintf("this is the last version of %s", document.ID)
+	} else {
+		goToTransaction(version)
+	}
+}
+
+// jumpToCreation moves to the transaction that created the DID document in the current transaction, by following its
+// previous versions back to the first one.
+func jumpToCreation() {
+	tx, document := currentDIDDocument()
+	if document == nil {
+		return
+	}
+	creation := tx
+	for {
+		version, err := previousVersion(creation, document)
+		if err != nil {
+			statusMessage = err.Error()
+			return
+		}
+		if version == nil {
+			break
+		}
+		creation = version
+	}
+	if creation == tx {
+		statusMessage = fmt.Sprintf("this transaction created %s", document.ID)
+		return
+	}
+	goToTransaction(creation)
+}
+
+// currentDIDDocument returns the current transaction and the DID document it contains. If there's no transaction or it
+// doesn't contain a DID document, the document is nil and the reason is set as status message.
+func currentDIDDocument() (dag.Transaction, *did.Document) {
+	if dagSubIndex >= len(transactions[dagLamportClock]) {
+		return nil, nil
+	}
+	tx, err := dag.ParseTransaction([]byte(transactions[dagLamportClock][dagSubIndex]))
+	if err != nil {
+		statusMessage = fmt.Sprintf("failed to parse transaction: %v", err)
+		return nil, nil
+	}
+	document := transactionDIDDocument(tx)
+	if document == nil {
+		statusMessage = "not a DID document transaction"
+	}
+	return tx, document
+}
+
+// transactionDIDDocument returns the DID document in the given transaction, or nil if it doesn't contain one
+func transactionDIDDocument(tx dag.Transaction) *did.Document {
+	if tx.PayloadType() != didDocumentType {
+		return nil
+	}
+	payload, err := loadPayload(tx.Ref())
+	if err != nil {
+		return nil
+	}
+	return parseDIDDocument(payload)
+}
+
+// fetchDIDDocument returns the DID document in the given transaction, fetching its payload with the given client. It
+// returns nil if the transaction doesn't contain one (or its payload can't be fetched).
+func fetchDIDDocument(ctx context.Context, client *Client, tx dag.Transaction) *did.Document {
+	if tx.PayloadType() != didDocumentType {
+		return nil
+	}
+	payload, err := client.TransactionPayload(ctx, tx.Ref())
+	if err != nil {
+		return nil
+	}
+	return parseDIDDocument(payload)
+}
+
+// parseDIDDocument returns the DID document in the given payload, or nil if it isn't one
+func parseDIDDocument(payload []byte) *did.Document {
+	if jsonKind(payload) != "object" {
+		return nil
+	}
+	document := &did.Document{}
+	if err := json.Unmarshal(payload, document); err != nil {
+		return nil
+	}
+	return document
+}
+
+// previousVersion walks the parents of the given transaction to find the previous version of the given DID document.
+// It returns nil if the transaction created the DID document.
+func previousVersion(tx dag.Transaction, document *did.Document) (dag.Transaction, error) {
+	for _, note := range analyzers.Classify(tx, document) {
+		if note == "created" {
+			return nil, nil
+		}
+	}
+	return latestVersionBefore(viewerContext, client, tx.Previous(), document.ID.String())
+}
+
+// latestVersionBefore walks the given transactions and their parents to find the latest version of the DID document
+// with the given ID, which is the one the given transactions (directly or indirectly) refer to. It returns nil if
+// there's none.
+func latestVersionBefore(ctx context.Context, client *Client, refs []hash.SHA256Hash, id string) (dag.Transaction, error) {
+	var result dag.Transaction
+	visited := make(map[hash.SHA256Hash]bool)
+	queue := append([]hash.SHA256Hash(nil), refs...)
+	for len(queue) > 0 {
+		ref := queue[0]
+		queue = queue[1:]
+		if visited[ref] {
+			continue
+		}
+		visited[ref] = true
+		rawTransaction, err := client.Transaction(ctx, ref)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get transaction %s: %w", ref, err)
+		}
+		parent, err := dag.ParseTransaction([]byte(rawTransaction))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse transaction %s: %w", ref, err)
+		}
+		// Anything before a version that was already found can't be the latest version
+		if result != nil && parent.Clock() <= result.Clock() {
+			continue
+		}
+		if parentDocument := fetchDIDDocument(ctx, client, parent); parentDocument != nil && parentDocument.ID.String() == id {
+			result = parent
+			continue
+		}
+		queue = append(queue, parent.Previous()...)
+	}
+	return result, nil
+}
+
+// nextVersion scans the lamport clocks after the given transaction up to the given highest clock of the DAG (see
+// Client.Head) for the next version of the given DID, which refers to it (see versionScan). It returns nil if there's
+// none.
+func nextVersion(tx dag.Transaction, id string, highestClock int) (dag.Transaction, error) {
+	scan := newVersionScan(tx, id, highestClock)
+	for len(scan.versions) == 0 && !scan.done() {
+		if err := scan.step(); err != nil {
+			return nil, err
+		}
+	}
+	if len(scan.versions) == 0 {
+		return nil, nil
+	}
+	return scan.versions[0], nil
+}
+
+// versionScan scans the lamport clocks after a version of a DID document for the versions that follow it, one batch of
+// lamport clocks at a time. A version follows the one before it if it refers to it, directly or through other
+// transactions: concurrent versions on other branches don't. So only the payloads of DID document transactions that
+// refer to the last version found are fetched.
+type versionScan struct {
+	id           string
+	start        int
+	highestClock int
+	// descendants are the transactions that refer to the last version found, directly or indirectly
+	descendants map[hash.SHA256Hash]bool
+	// versions are the versions found so far, oldest first
+	versions []dag.Transaction
+}
+
+// newVersionScan returns a scan for the versions of the given DID that follow the given transaction, up to the given
+// highest clock of the DAG
+func newVersionScan(tx dag.Transaction, id string, highestClock int) *versionScan {
+	return &versionScan{
+		id:           id,
+		start:        int(tx.Clock()) + 1,
+		highestClock: highestClock,
+		descendants:  map[hash.SHA256Hash]bool{tx.Ref(): true},
+	}
+}
+
+// done returns whether the scan reached the highest clock
+func (s *versionScan) done() bool {
+	return s.start > s.highestClock
+}
+
+// step scans the next batch of lamport clocks, adding the versions it finds to versions
+func (s *versionScan) step() error {
+	rawTransactions, err := client.TransactionsInRange(viewerContext, s.start, s.start+versionScanBatch)
+	if err != nil {
+		return fmt.Errorf("failed to get transactions from lamport clock %d: %w", s.start, err)
+	}
+	s.start += versionScanBatch
+	var batch []dag.Transaction
+	for _, rawTransaction := range rawTransactions {
+		if tx, err := dag.ParseTransaction([]byte(rawTransaction)); err == nil {
+			batch = append(batch, tx)
+		}
+	}
+	// Transactions always have a higher lamport clock than the ones they refer to
+	sort.SliceStable(batch, func(i, j int) bool {
+		return batch[i].Clock() < batch[j].Clock()
+	})
+	for _, candidate := range batch {
+		if !refersToAny(candidate, s.descendants) {
+			continue
+		}
+		s.descendants[candidate.Ref()] = true
+		if candidateDocument := transactionDIDDocument(candidate); candidateDocument != nil && candidateDocument.ID.String() == s.id {
+			s.versions = append(s.versions, candidate)
+			// The next version must follow this one
+			s.descendants = map[hash.SHA256Hash]bool{candidate.Ref(): true}
+		}
+	}
+	return nil
+}
+
+// refersToAny returns whether the given transaction refers to any of the given references as previous transaction
+func refersToAny(tx dag.Transaction, refs map[hash.SHA256Hash]bool) bool {
+	for _, prev := range tx.Previous() {
+		if refs[prev] {
+			return true
+		}
+	}
+	return false
+}
+
+// goToTransaction moves the viewer to the given transaction
+func goToTransaction(tx dag.Transaction) {
+	dagLamportClock = int(tx.Clock())
+	dagSubIndex = 0
+	loadTransactions(dagLamportClock)
+	for i, rawTransaction := range transactions[dagLamportClock] {
+		if hash.SHA256Sum([]byte(rawTransaction)).Equals(tx.Ref()) {
+			dagSubIndex = i
+		}
+	}
+}
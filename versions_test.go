@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/nuts-foundation/nuts-node/crypto/hash"
+	"github.com/nuts-foundation/nuts-node/network/dag"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTransactionDIDDocument(t *testing.T) {
+	testCases := []struct {
+		name     string
+		payload  string
+		expected string
+	}{
+		{"DID document", `{"id":"did:nuts:A"}`, "did:nuts:A"},
+		{"array", `[{"id":"did:nuts:A"}]`, ""},
+		{"scalar", `"did:nuts:A"`, ""},
+		{"null", `null`, ""},
+		{"not JSON", `hello`, ""},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			tx, err := dag.ParseTransaction([]byte(withCachedPayload(t, testCase.payload)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			document := transactionDIDDocument(tx)
+
+			if testCase.expected == "" {
+				if document != nil {
+					t.Errorf("expected no DID document, got %s", document.ID)
+				}
+			} else if document == nil || document.ID.String() != testCase.expected {
+				t.Errorf("expected DID document %s, got %v", testCase.expected, document)
+			}
+		})
+	}
+}
+
+// testViewerDAG is a DAG of transactions with their payloads, which is served to the client of the viewer by withDAG
+type testViewerDAG struct {
+	transactions []dag.Transaction
+	payloads     map[hash.SHA256Hash]string
+	// fetchedPayloads counts the payload requests per transaction
+	fetchedPayloads map[hash.SHA256Hash]int
+	// scannedRanges counts the requests for a batch of lamport clocks (see versionScanBatch)
+	scannedRanges int
+}
+
+// add adds a DID document transaction containing a document with the given ID, referring to the given previous
+// transactions. A transaction without previous transactions creates the DID document.
+func (d *testViewerDAG) add(id string, prevs ...dag.Transaction) dag.Transaction {
+	tx := dag.CreateSignedTestTransaction(uint32(len(d.transactions)+1), time.Now(), nil, didDocumentType, len(prevs) == 0, prevs...)
+	d.transactions = append(d.transactions, tx)
+	d.payloads[tx.Ref()] = fmt.Sprintf(`{"id":"%s"}`, id)
+	return tx
+}
+
+// withDAG has the client of the viewer read from a node serving a DAG (see testViewerDAG.add) for the duration of the
+// test
+func withDAG(t *testing.T) *testViewerDAG {
+	d := &testViewerDAG{payloads: make(map[hash.SHA256Hash]string), fetchedPayloads: make(map[hash.SHA256Hash]int)}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/status/diagnostics" {
+			highestClock := 0
+			for _, tx := range d.transactions {
+				if int(tx.Clock()) > highestClock {
+					highestClock = int(tx.Clock())
+				}
+			}
+			_, _ = fmt.Fprintf(w, `{"network":{"state":{"dag_lc_high":%d,"transaction_count":%d}}}`, highestClock, len(d.transactions))
+			return
+		}
+		if r.URL.Path == "/internal/network/v1/transaction" {
+			start, _ := strconv.Atoi(r.URL.Query().Get("start"))
+			end, _ := strconv.Atoi(r.URL.Query().Get("end"))
+			if end-start == versionScanBatch {
+				d.scannedRanges++
+			}
+			result := []string{}
+			for _, tx := range d.transactions {
+				if int(tx.Clock()) >= start && int(tx.Clock()) < end {
+					result = append(result, string(tx.Data()))
+				}
+			}
+			_ = json.NewEncoder(w).Encode(result)
+			return
+		}
+		for _, tx := range d.transactions {
+			if r.URL.Path == "/internal/network/v1/transaction/"+tx.Ref().String() {
+				_, _ = w.Write(tx.Data())
+				return
+			}
+			if r.URL.Path == "/internal/network/v1/transaction/"+tx.Ref().String()+"/payload" {
+				d.fetchedPayloads[tx.Ref()]++
+				_, _ = w.Write([]byte(d.payloads[tx.Ref()]))
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	originalClient := client
+	client = NewClient(server.URL)
+	payloadErrors = make(map[hash.SHA256Hash]error)
+	t.Cleanup(func() {
+		server.Close()
+		client = originalClient
+		payloadErrors = make(map[hash.SHA256Hash]error)
+	})
+	return d
+}
+
+func TestNextVersion(t *testing.T) {
+	// A is updated concurrently (left and right). The next version of the left update is the one merging both
+	// branches, through an unrelated DID document (B), not the later update on the right branch.
+	d := withDAG(t)
+	created := d.add("did:nuts:A")
+	left := d.add("did:nuts:A", created)
+	right := d.add("did:nuts:A", created)
+	onRight := d.add("did:nuts:A", right)
+	unrelated := d.add("did:nuts:B", left)
+	merge := d.add("did:nuts:A", onRight, unrelated)
+	name := map[hash.SHA256Hash]string{created.Ref(): "created", left.Ref(): "left", right.Ref(): "right",
+		onRight.Ref(): "on right", unrelated.Ref(): "unrelated", merge.Ref(): "merge"}
+	testCases := []struct {
+		name     string
+		tx       dag.Transaction
+		expected dag.Transaction
+	}{
+		{"created", created, left},
+		{"left", left, merge},
+		{"right", right, onRight},
+		{"on right", onRight, merge},
+		{"merge", merge, nil},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual, err := nextVersion(testCase.tx, "did:nuts:A", int(merge.Clock()))
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			expected, got := "none", "none"
+			if testCase.expected != nil {
+				expected = name[testCase.expected.Ref()]
+			}
+			if actual != nil {
+				got = name[actual.Ref()]
+			}
+			if got != expected {
+				t.Errorf("expected %s, got %s", expected, got)
+			}
+		})
+	}
+	t.Run("only payloads of transactions referring to the version are fetched", func(t *testing.T) {
+		client.ClearCache()
+		d.fetchedPayloads = make(map[hash.SHA256Hash]int)
+
+		_, _ = nextVersion(left, "did:nuts:A", int(merge.Clock()))
+
+		for ref := range d.fetchedPayloads {
+			if !ref.Equals(unrelated.Ref()) && !ref.Equals(merge.Ref()) {
+				t.Errorf("expected the payload of %s not to be fetched", name[ref])
+			}
+		}
+	})
+}
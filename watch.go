@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// watchDAG polls the highest lamport clock of the node at the given interval and calls regenerate whenever it
+// advances. It never returns.
+func watchDAG(client *Client, interval time.Duration, regenerate func()) {
+	head, err := client.Head(context.Background())
+	if err != nil {
+		log.Printf("failed to get the DAG head: %v", err)
+	}
+	for {
+		time.Sleep(interval)
+		current, err := client.Head(context.Background())
+		if err != nil {
+			log.Printf("failed to get the DAG head: %v", err)
+			continue
+		}
+		if current.HighestClock > head.HighestClock {
+			log.Printf("DAG head advanced to lamport clock %d, regenerating", current.HighestClock)
+			head = current
+			regenerate()
+		}
+	}
+}